@@ -78,19 +78,42 @@ import (
 	"time"
 )
 
-var output = writer{os.Stdout}
-
-// SetOutput overrides the logging output writer.
+// SetOutput overrides the primary logging output writer, which is
+// os.Stdout by default. The primary output takes lines of every severity,
+// rendered by the encoder set with SetEncoder. Outputs added with
+// AddOutput are kept.
 //
 // If the provided writer is an *os.File, or otherwise has a 'Sync() error'
 // method, it is called periodically (and by Flush) to commit pending data.
+// A 'Flush() error' method, as of a *bufio.Writer, is called first.
 func SetOutput(w io.Writer) {
-	output = writer{w}
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	logging.setOutput(newWriter(w))
+}
+
+type writer struct {
+	io.Writer
+	// autoColor reports whether lines are colored with ColorAuto: Writer
+	// is a terminal and NO_COLOR was not set when the writer was made.
+	autoColor bool
+	// min is the least severity written to the output.
+	min severity
+	// enc renders lines for the output, or is nil for the encoder set
+	// with SetEncoder.
+	enc Encoder
+	// flush determines when the output is flushed.
+	flush OutputFlush
 }
 
-type writer struct{ io.Writer }
+func newWriter(w io.Writer) *writer {
+	return &writer{Writer: w, autoColor: autoColor(w)}
+}
 
 func (w writer) Flush() {
+	if f, ok := w.Writer.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
 	if s, ok := w.Writer.(interface{ Sync() error }); ok {
 		_ = s.Sync()
 	}
@@ -102,7 +125,7 @@ var ExternalOutput externalWriter
 type externalWriter struct{}
 
 func (er externalWriter) Write(b []byte) (n int, err error) {
-	logging.printWithDepth(infoLog, 3, string(b))
+	logging.printWithDepth(infoLog, 3, nil, string(b))
 	return len(b), nil
 }
 
@@ -129,6 +152,16 @@ var severityName = []string{
 	fatalLog:   "FATAL",
 }
 
+// severityByName returns the severity with the given name.
+func severityByName(s string) (severity, bool) {
+	for i, name := range severityName {
+		if name == s {
+			return severity(i), true
+		}
+	}
+	return 0, false
+}
+
 // OutputStats tracks the number of output lines and bytes written.
 type OutputStats struct {
 	lines int64
@@ -147,14 +180,26 @@ func (s *OutputStats) Bytes() int64 {
 
 // Stats tracks the number of lines of output and number of bytes
 // per severity level. Values must be read with atomic.LoadInt64.
+// GetMetrics returns these along with further metrics.
 var Stats struct {
-	Info, Warning, Error OutputStats
+	Info, Warning, Error, Fatal OutputStats
+	// WriteErrors tracks the lines that could not be written to the
+	// output writer, and the number of bytes of them left unwritten.
+	WriteErrors OutputStats
+	// AsyncDropped tracks the lines dropped because the asynchronous
+	// output queue was full.
+	AsyncDropped OutputStats
+	// Truncated tracks the lines whose message or data was cut to the
+	// limits set by SetMaxRecordSize and SetMaxDataSize, and the number
+	// of bytes cut from them.
+	Truncated OutputStats
 }
 
 var severityStats = [numSeverity]*OutputStats{
 	infoLog:    &Stats.Info,
 	warningLog: &Stats.Warning,
 	errorLog:   &Stats.Error,
+	fatalLog:   &Stats.Fatal,
 }
 
 // Level is exported because it appears in the arguments to V and is
@@ -331,8 +376,11 @@ var errTraceSyntax = errors.New("syntax error: expect file.go:234")
 func (t *traceLocation) Set(value string) error {
 	if value == "" {
 		// Unset.
+		logging.mu.Lock()
+		defer logging.mu.Unlock()
 		t.line = 0
 		t.file = ""
+		return nil
 	}
 	fields := strings.Split(value, ":")
 	if len(fields) != 2 {
@@ -361,6 +409,7 @@ func init() {
 	flag.Var(&logging.vmodule, "vmodule", "comma-separated list of pattern=N settings for file-filtered logging")
 	flag.Var(&logging.traceLocation, "log_backtrace_at", "when logging hits line file:N, emit a stack trace")
 
+	logging.outputs = []*writer{newWriter(os.Stdout)}
 	log.SetOutput(ExternalOutput)
 	log.SetFlags(0)
 
@@ -382,6 +431,12 @@ type loggingT struct {
 	// for better parallelization.
 	freeListMu sync.Mutex
 
+	// writeErr is the most recent error from the output writer, maintained
+	// under writeErrMu. It is separate from the main mutex so it can be read
+	// without waiting for a write in progress.
+	writeErr   error
+	writeErrMu sync.Mutex
+
 	// mu protects the remaining elements of this structure and is
 	// used to synchronize logging.
 	mu sync.Mutex
@@ -394,6 +449,11 @@ type loggingT struct {
 	// than zero, it means vmodule is enabled. It may be read safely
 	// using sync.LoadInt32, but is only modified under mu.
 	filterLength int32
+	// outputs holds the primary output, set by SetOutput, followed by
+	// those added with AddOutput. It is copied on write.
+	outputs []*writer
+	// async, if not nil, writes output on its own goroutine.
+	async *asyncWriter
 	// traceLocation is the state of the -log_backtrace_at flag.
 	traceLocation traceLocation
 	// These flags are modified only under lock, although verbosity may be fetched
@@ -406,6 +466,7 @@ type loggingT struct {
 type buffer struct {
 	bytes.Buffer
 	tmp  [64]byte // temporary byte array for creating headers.
+	rec  record   // the logging call being formatted.
 	next *buffer
 }
 
@@ -454,6 +515,7 @@ func (l *loggingT) putBuffer(b *buffer) {
 		// Let big buffers die a natural death.
 		return
 	}
+	b.rec = record{}
 	l.freeListMu.Lock()
 	b.next = l.freeList
 	l.freeList = b
@@ -467,33 +529,53 @@ header formats a log header as defined by the C++ implementation.
 It returns a buffer containing the formatted header.
 
 Log lines have this form:
-	Lmmdd hh:mm:ss.uuuuuu threadid file:line] msg...
+	Lmmdd hh:mm:ss.uuuuuu file:line] msg...
 where the fields are defined as follows:
 	L                A single character, representing the log level (eg 'I' for INFO)
 	mm               The month (zero padded; ie May is '05')
 	dd               The day (zero padded)
 	hh:mm:ss.uuuuuu  Time in hours, minutes and fractional seconds
-	threadid         The space-padded thread ID as returned by GetTID()
 	file             The file name
 	line             The line number
 	msg              The user-supplied message
+
+SetHeaderLayout adds to this the year (Lyyyymmdd), nanoseconds
+(hh:mm:ss.nnnnnnnnn), the time zone offset (-0700) after the time, a
+thread ID in the C++ position before the file, made of the space-padded
+process ID or the goroutine ID, the full or relative path of the file,
+and the function name after the line number.
 */
 func (l *loggingT) header(s severity) *buffer {
 	return l.headerWithDepth(s, 1)
 }
 
 func (l *loggingT) headerWithDepth(s severity, extraDepth int) *buffer {
-	// Lmmdd hh:mm:ss.uuuuuu threadid file:line]
+	return l.encodeHeader(classicEncoder{}, s, extraDepth+1, "", nil)
+}
+
+// encodeHeader records the severity, time and location of a logging call,
+// along with the prefix and data given, in a new buffer, and writes the
+// header for enc.
+func (l *loggingT) encodeHeader(enc Encoder, s severity, extraDepth int, prefix string, data []interface{}) *buffer {
+	buf := l.getBuffer()
+	buf.rec = newRecord(s, extraDepth+1, prefix, data)
+	enc.header(buf)
+	return buf
+}
+
+// newRecord records the severity, time and location of a logging call,
+// along with the prefix and data given.
+func newRecord(s severity, extraDepth int, prefix string, data []interface{}) record {
 	now := timeNow()
-	_, file, line, ok := runtime.Caller(3 + extraDepth) // It's always the same number of frames to the user's call.
+	var file string
+	var line int
+	site, ok := caller(3 + extraDepth) // It's always the same number of frames to the user's call.
 	if !ok {
 		file = "???"
 		line = 1
 	} else {
-		slash := strings.LastIndex(file, "/")
-		if slash >= 0 {
-			file = file[slash+1:]
-		}
+		atomic.AddInt64(&site.hits, 1)
+		file, line = site.file, site.line
 	}
 	if line < 0 {
 		line = 0 // not a real line number, but acceptable to someDigits
@@ -501,32 +583,140 @@ func (l *loggingT) headerWithDepth(s severity, extraDepth int) *buffer {
 	if s > fatalLog {
 		s = infoLog // for safety.
 	}
-	buf := l.getBuffer()
+	r := record{s: s, time: now, file: file, line: line, prefix: prefix, data: data}
+	if ok {
+		r.function = site.function
+	}
+	return r
+}
+
+// classicEncoder writes the header described at header, and leaves the
+// message as it is.
+type classicEncoder struct{}
+
+func (classicEncoder) header(buf *buffer) {
+	r := &buf.rec
+	h := currentLayout()
+	now := r.time
+	if h.UTC {
+		now = now.UTC()
+	}
 
 	// Avoid Fprintf, for speed. The format is so simple that we can do it quickly by hand.
 	// It's worth about 3X. Fprintf is hard.
-	_, month, day := now.Date()
+	year, month, day := now.Date()
 	hour, minute, second := now.Clock()
-	buf.tmp[0] = severityChar[s]
-	buf.twoDigits(1, int(month))
-	buf.twoDigits(3, day)
-	buf.tmp[5] = ' '
-	buf.twoDigits(6, hour)
-	buf.tmp[8] = ':'
-	buf.twoDigits(9, minute)
-	buf.tmp[11] = ':'
-	buf.twoDigits(12, second)
-	buf.tmp[14] = '.'
-	buf.nDigits(6, 15, now.Nanosecond()/1000)
-	buf.tmp[21] = ' '
-	buf.Write(buf.tmp[:22])
-	buf.WriteString(file)
+	buf.tmp[0] = severityChar[r.s]
+	i := 1
+	if h.Year {
+		buf.nDigits(4, i, year, '0')
+		i += 4
+	}
+	buf.twoDigits(i, int(month))
+	buf.twoDigits(i+2, day)
+	buf.tmp[i+4] = ' '
+	buf.twoDigits(i+5, hour)
+	buf.tmp[i+7] = ':'
+	buf.twoDigits(i+8, minute)
+	buf.tmp[i+10] = ':'
+	buf.twoDigits(i+11, second)
+	buf.tmp[i+13] = '.'
+	i += 14
+	if h.Nanoseconds {
+		buf.nDigits(9, i, now.Nanosecond(), '0')
+		i += 9
+	} else {
+		buf.nDigits(6, i, now.Nanosecond()/1000, '0')
+		i += 6
+	}
+	if h.TimeZone {
+		_, offset := now.Zone()
+		buf.tmp[i] = ' '
+		buf.tmp[i+1] = '+'
+		if offset < 0 {
+			buf.tmp[i+1] = '-'
+			offset = -offset
+		}
+		buf.twoDigits(i+2, offset/3600)
+		buf.twoDigits(i+4, offset/60%60)
+		i += 6
+	}
+	buf.tmp[i] = ' '
+	i++
+	switch h.ThreadID {
+	case ThreadIDProcess:
+		buf.nDigits(7, i, pid, ' ')
+		buf.tmp[i+7] = ' '
+		i += 8
+	case ThreadIDGoroutine:
+		i += goroutineID(buf.tmp[i:])
+		buf.tmp[i] = ' '
+		i++
+	}
+	buf.Write(buf.tmp[:i])
+	r.locStart = buf.Len()
+	buf.WriteString(h.Path.format(r.file))
 	buf.tmp[0] = ':'
-	n := buf.someDigits(1, line)
-	buf.tmp[n+1] = ']'
-	buf.tmp[n+2] = ' '
-	buf.Write(buf.tmp[:n+3])
-	return buf
+	n := buf.someDigits(1, r.line)
+	buf.Write(buf.tmp[:n+1])
+	r.locEnd = buf.Len()
+	if h.Function && r.function != "" {
+		buf.WriteByte(' ')
+		buf.WriteString(r.function)
+	}
+	buf.WriteString("] ")
+}
+
+func (classicEncoder) finish(buf *buffer, start int) {}
+
+func (classicEncoder) structured() bool { return false }
+
+// callSite is the source location of a logging call.
+type callSite struct {
+	file     string
+	line     int
+	function string
+	hits     int64 // number of times the call was made; use atomic ops
+}
+
+// callSites caches the location of each logging call by PC, because
+// runtime.Caller allocates and the PC alone can be found without doing so.
+var callSites struct {
+	sync.RWMutex
+	m map[uintptr]*callSite
+}
+
+// caller is equivalent to runtime.Caller, but returns the call site and
+// does not allocate once the call site has been seen before.
+func caller(skip int) (site *callSite, ok bool) {
+	var pcs [1]uintptr
+	if runtime.Callers(skip+2, pcs[:]) == 0 {
+		return nil, false
+	}
+	return siteForPC(pcs[0]), true
+}
+
+// siteForPC returns the call site for a PC as returned by runtime.Callers.
+func siteForPC(pc uintptr) *callSite {
+	callSites.RLock()
+	site, ok := callSites.m[pc]
+	callSites.RUnlock()
+	if ok {
+		return site
+	}
+
+	callSites.Lock()
+	defer callSites.Unlock()
+	if site, ok = callSites.m[pc]; ok {
+		return site
+	}
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	site = &callSite{file: frame.File, line: frame.Line, function: frame.Function}
+	if callSites.m == nil {
+		callSites.m = make(map[uintptr]*callSite)
+	}
+	callSites.m[pc] = site
+	return site
 }
 
 // Some custom tiny helper functions to print the log header efficiently.
@@ -540,12 +730,18 @@ func (buf *buffer) twoDigits(i, d int) {
 	buf.tmp[i] = digits[d%10]
 }
 
-// nDigits formats a zero-prefixed n-digit integer at buf.tmp[i].
-func (buf *buffer) nDigits(n, i, d int) {
-	for j := n - 1; j >= 0; j-- {
+// nDigits formats an n-digit integer at buf.tmp[i],
+// padding with pad on the left.
+// It assumes d >= 0.
+func (buf *buffer) nDigits(n, i, d int, pad byte) {
+	j := n - 1
+	for ; j >= 0 && d > 0; j-- {
 		buf.tmp[i+j] = digits[d%10]
 		d /= 10
 	}
+	for ; j >= 0; j-- {
+		buf.tmp[i+j] = pad
+	}
 }
 
 // someDigits formats a zero-prefixed variable-width integer at buf.tmp[i].
@@ -565,7 +761,7 @@ func (buf *buffer) someDigits(i, d int) int {
 }
 
 func (l *loggingT) println(s severity, args ...interface{}) {
-	l.printlnWithDepth(s, 1, args...)
+	l.printlnWithDepth(s, 1, nil, args...)
 }
 
 // getEvent exists to give some extra flexiblity to glog-compatible add-ons
@@ -574,127 +770,392 @@ func (l *loggingT) println(s severity, args ...interface{}) {
 func (l *loggingT) getEvent(s severity, args ...interface{}) Event {
 	args, dataArgs := filterData(args)
 	buf := l.headerWithDepth(s, 1)
-	fmt.Fprintln(buf, args...)
+	start := buf.Len()
+	fmt.Fprintln(buf, sanitizeArgs(nil, redactArgs(args), false)...)
+	scrub(buf, start)
+	dataArgs, cut := capData(dataArgs)
+	countTruncated(cut + truncateMessage(buf, start, int(atomic.LoadInt64(&maxRecordSize))))
 
 	message := buf.Bytes()
 	mess := make([]byte, len(message))
 	copy(mess, message)
 
-	return NewEvent(s, mess, dataArgs, 1)
+	e := NewEvent(s, mess, dataArgs, 1)
+	e.Body = strings.TrimSuffix(string(message[start:]), "\n")
+	return e
 }
 
-// formatErrors prints errors with detail, to get stack traces for xerrors.
+// formatErrors prints errors with detail, to get stack traces for xerrors,
+// and replaces Redactors with their redacted form.
+// It returns args itself, rather than a copy, if there are neither.
 func formatErrors(args []interface{}) []interface{} {
-	var r = make([]interface{}, len(args))
+	var r []interface{}
 	for i, arg := range args {
-		if err, ok := arg.(error); ok {
-			r[i] = fmt.Sprintf("%+v", err)
-		} else {
-			r[i] = arg
+		var formatted interface{}
+		switch arg := arg.(type) {
+		case Redactor:
+			formatted = Redacted(arg.Redact())
+		case error:
+			formatted = fmt.Sprintf("%+v", arg)
+		default:
+			continue
+		}
+		if r == nil {
+			r = make([]interface{}, len(args))
+			copy(r, args)
 		}
+		r[i] = formatted
+	}
+	if r == nil {
+		return args
 	}
 	return r
 }
 
-func (l *loggingT) printlnWithDepth(s severity, extraDepth int, args ...interface{}) {
-	args, dataArgs := filterData(args)
-	buf := l.headerWithDepth(s, extraDepth)
-	fmt.Fprintln(buf, formatErrors(args)...)
-
-	message := buf.Bytes()
-	mess := make([]byte, len(message))
-	copy(mess, message)
-
-	l.outputWithDepth(s, buf, extraDepth)
-
-	e := NewEvent(s, mess, dataArgs, extraDepth)
-	eventForBackends(e)
+// redactArgs replaces Redactors in args with their redacted form.
+// It returns args itself, rather than a copy, if there are none.
+func redactArgs(args []interface{}) []interface{} {
+	var r []interface{}
+	for i, arg := range args {
+		if _, ok := arg.(Redactor); !ok {
+			continue
+		}
+		if r == nil {
+			r = make([]interface{}, len(args))
+			copy(r, args)
+		}
+		r[i] = redact(arg)
+	}
+	if r == nil {
+		return args
+	}
+	return r
 }
 
-func (l *loggingT) print(s severity, args ...interface{}) int {
-	return l.printWithDepth(s, 1, args...)
+// copyBytes returns a copy of b, for events that outlive the buffer they
+// were formatted in.
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
 }
 
-func (l *loggingT) printWithDepth(s severity, extraDepth int, args ...interface{}) int {
-	args, dataArgs := filterData(args)
-	buf := l.headerWithDepth(s, extraDepth)
-	fmt.Fprint(buf, formatErrors(args)...)
-
-	message := buf.Bytes()
-	mess := make([]byte, len(message))
-	copy(mess, message)
-
-	if buf.Bytes()[buf.Len()-1] != '\n' {
-		buf.WriteByte('\n')
+func (l *loggingT) printlnWithDepth(s severity, extraDepth int, lg *Logger, args ...interface{}) {
+	args, dataArgs, send := splitArgs(args, l.structured())
+	args = sanitizeArgs(lg, formatErrors(args), false)
+	prefix := lg.prefixString()
+	var sep string
+	if prefix != "" && len(args) > 0 {
+		sep = " "
 	}
-	n := l.outputWithDepth(s, buf, extraDepth)
-
-	e := NewEvent(s, mess, dataArgs, extraDepth)
-	eventForBackends(e)
-	return n
+	l.log(s, extraDepth, prefix, sep, dataArgs, send, nil, func(buf *buffer) {
+		fmt.Fprintln(buf, args...)
+	}, nil)
 }
 
-func (l *loggingT) printf(s severity, format string, args ...interface{}) {
-	l.printfWithDepth(s, 1, format, args...)
+func (l *loggingT) print(s severity, args ...interface{}) int {
+	return l.printWithDepth(s, 1, nil, args...)
 }
 
-func (l *loggingT) printfWithDepth(s severity, extraDepth int, format string, args ...interface{}) {
-	args, dataArgs := filterData(args)
-	buf := l.headerWithDepth(s, extraDepth)
-	fmt.Fprintf(buf, format, formatErrors(args)...)
-
-	message := buf.Bytes()
-	mess := make([]byte, len(message))
-	copy(mess, message)
+func (l *loggingT) printWithDepth(s severity, extraDepth int, lg *Logger, args ...interface{}) int {
+	args, dataArgs, send := splitArgs(args, l.structured())
+	args = sanitizeArgs(lg, formatErrors(args), false)
+	return l.log(s, extraDepth, lg.prefixString(), "", dataArgs, send, nil, func(buf *buffer) {
+		fmt.Fprint(buf, args...)
+	}, nil)
+}
 
-	if buf.Bytes()[buf.Len()-1] != '\n' {
-		buf.WriteByte('\n')
+func (l *loggingT) printf(s severity, format string, args ...interface{}) {
+	l.printfWithDepth(s, 1, nil, format, args...)
+}
+
+func (l *loggingT) printfWithDepth(s severity, extraDepth int, lg *Logger, format string, args ...interface{}) {
+	args, dataArgs, send := splitArgs(args, l.structured())
+	args = sanitizeArgs(lg, formatErrors(args), true)
+	prefix := lg.prefixString()
+	classicFormat := format
+	var (
+		sep           string
+		formatClassic func(buf *buffer)
+	)
+	if lg != nil {
+		// The prefix is part of the format string, even when empty.
+		classicFormat = lg.pfx(format)
+		sep = " "
+		if strings.Contains(prefix, "%") {
+			// The prefix is formatted along with the arguments then.
+			formatClassic = func(buf *buffer) {
+				fmt.Fprintf(buf, classicFormat, args...)
+			}
+		}
 	}
-	l.outputWithDepth(s, buf, extraDepth)
-
 	// NOTE(jwoglom): add format string argument as data field
 	// that can be parsed by backends.
-	dataArgs = append(dataArgs, FormatStringArg{format})
-	e := NewEvent(s, mess, dataArgs, extraDepth)
-	eventForBackends(e)
+	formatArg := FormatStringArg{classicFormat}
+	l.log(s, extraDepth, prefix, sep, dataArgs, send, &formatArg, func(buf *buffer) {
+		fmt.Fprintf(buf, format, args...)
+	}, formatClassic)
+}
+
+// structured reports whether any output renders data as fields, so that
+// it must be kept even with no backends to send it to.
+func (l *loggingT) structured() bool {
+	return currentEncoder().structured() || atomic.LoadInt32(&structuredOutputs) > 0
+}
+
+// message is the message of a logging call. Structured encoders write
+// text, the formatted arguments. The others write the prefix of the
+// Logger, then sep, then text, or classic if it is set.
+type message struct {
+	prefix, sep   string
+	text, classic []byte
+}
+
+// renderedLine is a line rendered by an encoder.
+type renderedLine struct {
+	enc Encoder
+	buf *buffer
+}
+
+// log renders a logging call, passes it to the backends if send is set,
+// and writes it to the outputs. The arguments are formatted by format,
+// and by formatClassic, if not nil, for encoders that are not structured,
+// which otherwise write the prefix and sep before them. The event's data
+// is dataArgs followed by formatArg, if not nil.
+func (l *loggingT) log(s severity, extraDepth int, prefix, sep string, dataArgs []interface{}, send bool, formatArg *FormatStringArg, format, formatClassic func(buf *buffer)) int {
+	dataArgs, cut := capData(dataArgs)
+	rec := newRecord(s, extraDepth+1, prefix, dataArgs)
+	enc := currentEncoder()
+	encs, _ := outputEncoders.Load().([]outputEncoder)
+
+	// The arguments are formatted once for each form of the message in
+	// use, whatever the number of encoders, and before l.mu is taken, as
+	// formatting them may run code that logs. Events carry the classic
+	// form.
+	structured, classic := enc.structured(), !enc.structured() || send
+	for _, oe := range encs {
+		if s >= oe.min {
+			structured = structured || oe.enc.structured()
+			classic = classic || !oe.enc.structured()
+		}
+	}
+	msg := message{prefix: prefix, sep: sep}
+	var text, classicText *buffer
+	if structured || formatClassic == nil {
+		text = l.getBuffer()
+		format(text)
+		msg.text = text.Bytes()
+	}
+	if classic && formatClassic != nil {
+		classicText = l.getBuffer()
+		formatClassic(classicText)
+		msg.classic = classicText.Bytes()
+	}
+
+	buf, start, msgCut := l.render(enc, rec, msg)
+	countTruncated(cut + msgCut)
+
+	if send {
+		// Backends hear about the event first, as a FATAL will not return.
+		eventData := dataArgs
+		if formatArg != nil {
+			eventData = append(dataArgs[:len(dataArgs):len(dataArgs)], *formatArg)
+		}
+		e := NewEvent(s, copyBytes(buf.Bytes()), eventData, extraDepth+1)
+		e.Prefix = prefix
+		if enc.structured() {
+			body, bodyStart, _ := l.render(ClassicEncoder, rec, msg)
+			e.Body = string(body.Bytes()[bodyStart:])
+			l.putBuffer(body)
+		} else {
+			e.Body = string(buf.Bytes()[start:])
+		}
+		e.Body = strings.TrimSuffix(e.Body, "\n")
+		eventForBackends(e)
+	}
+	l.endLine(buf, start)
+
+	// Render the line for the other encoders of the outputs, so that only
+	// writing is left to do under l.mu.
+	var linesArray [4]renderedLine
+	lines := append(linesArray[:0], renderedLine{enc, buf})
+	for _, oe := range encs {
+		if s < oe.min || oe.enc == enc {
+			continue
+		}
+		buf, start, _ := l.render(oe.enc, rec, msg)
+		l.endLine(buf, start)
+		lines = append(lines, renderedLine{oe.enc, buf})
+	}
+	if text != nil {
+		l.putBuffer(text)
+	}
+	if classicText != nil {
+		l.putBuffer(classicText)
+	}
+	return l.outputWithDepth(s, lines, extraDepth+1)
 }
 
-// output writes the data to the log files and releases the buffer.
-func (l *loggingT) output(s severity, buf *buffer) {
-	l.outputWithDepth(s, buf, 1)
+// render writes the line for rec in a new buffer using enc, but without
+// the final newline. It returns the buffer, the offset of the message in
+// it and the number of bytes cut from the message.
+func (l *loggingT) render(enc Encoder, rec record, msg message) (buf *buffer, start, cut int) {
+	max := int(atomic.LoadInt64(&maxRecordSize))
+	limit := max
+	for {
+		buf = l.getBuffer()
+		buf.rec = rec
+		enc.header(buf)
+		start = buf.Len()
+		switch {
+		case enc.structured():
+			buf.Write(msg.text)
+		case msg.classic != nil:
+			buf.Write(msg.classic)
+		default:
+			buf.WriteString(msg.prefix)
+			buf.WriteString(msg.sep)
+			buf.Write(msg.text)
+		}
+		scrub(buf, start)
+		cut = truncateMessage(buf, start, limit)
+		enc.finish(buf, start)
+		// Quoting and the fields added by finish may take the line over
+		// the limit; if so, cut that much more of the message.
+		over := buf.Len() - max
+		if max <= 0 || over <= 0 || limit <= start {
+			return buf, start, cut
+		}
+		l.putBuffer(buf)
+		if limit -= over; limit < start {
+			limit = start
+		}
+	}
 }
 
-func (l *loggingT) outputWithDepth(s severity, buf *buffer, extraDepth int) int {
+// outputWithDepth writes lines, which hold the line rendered by each
+// encoder in use, starting with the one set with SetEncoder, to the
+// outputs that take lines of severity s, and releases them. Outputs
+// added since the lines were rendered, with an encoder for which there
+// is no line, are skipped. It returns the number of bytes written to the
+// first output.
+func (l *loggingT) outputWithDepth(s severity, lines []renderedLine, extraDepth int) int {
 	l.mu.Lock()
 	if l.traceLocation.isSet() {
 		_, file, line, ok := runtime.Caller(3 + extraDepth)
 		if ok && l.traceLocation.match(file, line) {
-			buf.Write(stacks(false))
+			trace := stacks(false)
+			for _, line := range lines {
+				line.buf.Write(trace)
+			}
+		}
+	}
+	if stats := severityStats[s]; stats != nil {
+		atomic.AddInt64(&stats.lines, 1)
+		atomic.AddInt64(&stats.bytes, int64(lines[0].buf.Len()))
+	}
+
+	var (
+		n       int
+		err     error
+		written bool
+	)
+	outputs := l.outputs
+	for i, w := range outputs {
+		if s < w.min {
+			continue
+		}
+		enc := w.encoder(lines[0].enc)
+		var line *buffer
+		for j := range lines {
+			if lines[j].enc == enc {
+				line = lines[j].buf
+				// Each buffer is handed over to the last output that
+				// takes it, and copied for the others.
+				if takesLine(s, outputs[i+1:], enc, lines[0].enc) {
+					line = l.copyBuffer(line)
+				} else {
+					lines[j].buf = nil
+				}
+				break
+			}
+		}
+		if line == nil {
+			continue
+		}
+		wn, werr := l.writeBuffer(w, line)
+		if !written {
+			n, written = wn, true
+		}
+		if werr != nil {
+			err = werr
+		}
+	}
+	for _, line := range lines {
+		if line.buf != nil {
+			l.putBuffer(line.buf)
 		}
 	}
-	data := buf.Bytes()
-	n, _ := output.Write(data)
 	if s == fatalLog {
 		// If we got here via Exit rather than Fatal, print no stacks.
-		if atomic.LoadUint32(&fatalNoStacks) > 0 {
-			l.mu.Unlock()
-			timeoutFlush(10 * time.Second)
-			os.Exit(1)
+		code := ExitCodeExit
+		if atomic.SwapUint32(&fatalNoStacks, 0) == 0 {
+			trace := stacks(false)
+			for _, w := range outputs {
+				b := l.getBuffer()
+				b.Write(trace)
+				l.writeBuffer(w, b)
+			}
+			code = ExitCodeFatal
 		}
-		output.Write(stacks(false))
 		l.mu.Unlock()
-		timeoutFlush(10 * time.Second)
-		os.Exit(255) // C++ uses -1, which is silly because it's anded with 255 anyway.
+		runExit(code)
+		// The exit function returned, so carry on as for any other severity.
+		l.mu.Lock()
 	}
-	l.putBuffer(buf)
 	l.mu.Unlock()
-	if stats := severityStats[s]; stats != nil {
-		atomic.AddInt64(&stats.lines, 1)
-		atomic.AddInt64(&stats.bytes, int64(len(data)))
+	if err != nil && getWriteErrorPolicy() == WriteErrorExit {
+		l.exit(err)
 	}
 	return n
 }
 
+// takesLine reports whether any of outputs takes lines of severity s
+// rendered by enc, where def is the encoder of outputs without their own.
+func takesLine(s severity, outputs []*writer, enc, def Encoder) bool {
+	for _, w := range outputs {
+		if s >= w.min && w.encoder(def) == enc {
+			return true
+		}
+	}
+	return false
+}
+
+// copyBuffer returns a new buffer holding the contents of buf.
+func (l *loggingT) copyBuffer(buf *buffer) *buffer {
+	c := l.getBuffer()
+	c.rec = buf.rec
+	c.Write(buf.Bytes())
+	return c
+}
+
+// writeBuffer writes buf to w and releases it, or hands it to the
+// asynchronous writer if there is one.
+// l.mu is held.
+func (l *loggingT) writeBuffer(w *writer, buf *buffer) (int, error) {
+	if w.colored() {
+		buf = l.colorize(buf)
+	}
+	if l.async != nil {
+		n := buf.Len()
+		l.async.enqueue(w, buf)
+		return n, nil
+	}
+	n, err := l.write(w, buf.Bytes(), 1)
+	l.putBuffer(buf)
+	return n, err
+}
+
 // timeoutFlush calls Flush and returns when it completes or after timeout
 // elapses, whichever happens first.  This is needed because the hooks invoked
 // by Flush may deadlock when glog.Fatal is called from a hook that holds
@@ -731,24 +1192,13 @@ func stacks(all bool) []byte {
 	return trace
 }
 
-// logExitFunc provides a simple mechanism to override the default behavior
-// of exiting on error. Used in testing and to guarantee we reach a required exit
-// for fatal logs. Instead, exit could be a function rather than a method but that
-// would make its use clumsier.
-var logExitFunc func(error)
-
 // exit is called if there is trouble creating or writing log files.
 // It flushes the logs and exits the program; there's no point in hanging around.
-// l.mu is held.
+// The exit function set by SetExitFunc may override this.
+// l.mu is not held.
 func (l *loggingT) exit(err error) {
 	fmt.Fprintf(os.Stderr, "log: exiting because of error: %s\n", err)
-	// If logExitFunc is set, we do that instead of exiting.
-	if logExitFunc != nil {
-		logExitFunc(err)
-		return
-	}
-	l.flushAll()
-	os.Exit(2)
+	runExit(ExitCodeWriteError)
 }
 
 const flushInterval = 30 * time.Second
@@ -770,7 +1220,14 @@ func (l *loggingT) lockAndFlushAll() {
 // flushAll flushes all the logs and attempts to "sync" their data to disk.
 // l.mu is held.
 func (l *loggingT) flushAll() {
-	output.Flush()
+	if l.async != nil {
+		l.async.wait()
+	}
+	for _, w := range l.outputs {
+		if w.flush != FlushNever {
+			w.Flush()
+		}
+	}
 }
 
 // setV computes and remembers the V level for a given PC
@@ -858,7 +1315,7 @@ func (v Verbose) Info(args ...interface{}) {
 // See the documentation of V for usage.
 func (v Verbose) InfoWithDepth(extraDepth int, args ...interface{}) {
 	if v {
-		logging.printWithDepth(infoLog, extraDepth, args...)
+		logging.printWithDepth(infoLog, extraDepth, nil, args...)
 	}
 }
 
@@ -874,7 +1331,7 @@ func (v Verbose) Infoln(args ...interface{}) {
 // See the documentation of V for usage.
 func (v Verbose) InfolnWithDepth(extraDepth int, args ...interface{}) {
 	if v {
-		logging.printlnWithDepth(infoLog, extraDepth, args...)
+		logging.printlnWithDepth(infoLog, extraDepth, nil, args...)
 	}
 }
 
@@ -890,7 +1347,7 @@ func (v Verbose) Infof(format string, args ...interface{}) {
 // See the documentation of V for usage.
 func (v Verbose) InfofWithDepth(extraDepth int, format string, args ...interface{}) {
 	if v {
-		logging.printfWithDepth(infoLog, extraDepth, format, args...)
+		logging.printfWithDepth(infoLog, extraDepth, nil, format, args...)
 	}
 }
 
@@ -903,7 +1360,7 @@ func Info(args ...interface{}) {
 // InfoWithDepth is equivalent to Info but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func InfoWithDepth(extraDepth int, args ...interface{}) {
-	logging.printWithDepth(infoLog, extraDepth, args...)
+	logging.printWithDepth(infoLog, extraDepth, nil, args...)
 }
 
 // Infoln logs to the INFO log.
@@ -915,7 +1372,7 @@ func Infoln(args ...interface{}) {
 // InfolnWithDepth is equivalent to Infoln but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
 func InfolnWithDepth(extraDepth int, args ...interface{}) {
-	logging.printlnWithDepth(infoLog, extraDepth, args...)
+	logging.printlnWithDepth(infoLog, extraDepth, nil, args...)
 }
 
 // Infof logs to the INFO log.
@@ -927,7 +1384,7 @@ func Infof(format string, args ...interface{}) {
 // InfofWithDepth is equivalent to Infof but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func InfofWithDepth(extraDepth int, format string, args ...interface{}) {
-	logging.printfWithDepth(infoLog, extraDepth, format, args...)
+	logging.printfWithDepth(infoLog, extraDepth, nil, format, args...)
 }
 
 // Warning logs to the WARNING and INFO logs.
@@ -953,7 +1410,7 @@ func WarningIf(err error, args ...interface{}) {
 // WarningWithDepth is equivalent to Warning but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func WarningWithDepth(extraDepth int, args ...interface{}) {
-	logging.printWithDepth(warningLog, extraDepth, args...)
+	logging.printWithDepth(warningLog, extraDepth, nil, args...)
 }
 
 // Warningln logs to the WARNING and INFO logs.
@@ -965,7 +1422,7 @@ func Warningln(args ...interface{}) {
 // WarninglnWithDepth is equivalent to Warningln but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
 func WarninglnWithDepth(extraDepth int, args ...interface{}) {
-	logging.printlnWithDepth(warningLog, extraDepth, args...)
+	logging.printlnWithDepth(warningLog, extraDepth, nil, args...)
 }
 
 // Warningf logs to the WARNING and INFO logs.
@@ -987,7 +1444,7 @@ func WarningfIf(err error, format string, args ...interface{}) {
 // WarningfWithDepth is equivalent to Warningf but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func WarningfWithDepth(extraDepth int, format string, args ...interface{}) {
-	logging.printfWithDepth(warningLog, extraDepth, format, args...)
+	logging.printfWithDepth(warningLog, extraDepth, nil, format, args...)
 }
 
 // Error logs to the ERROR, WARNING, and INFO logs.
@@ -998,7 +1455,7 @@ func Error(args ...interface{}) {
 
 // GetErrorEvent returns an ERROR level Event of args
 func GetErrorEvent(args ...interface{}) Event {
-	return logging.getEvent(errorLog, args)
+	return logging.getEvent(errorLog, args...)
 }
 
 // ErrorIf logs to the ERROR, WARNING, and INFO logs.
@@ -1016,7 +1473,7 @@ func ErrorIf(err error, args ...interface{}) {
 // ErrorWithDepth is equivalent to Error but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func ErrorWithDepth(extraDepth int, args ...interface{}) {
-	logging.printWithDepth(errorLog, extraDepth, args...)
+	logging.printWithDepth(errorLog, extraDepth, nil, args...)
 }
 
 // Errorln logs to the ERROR, WARNING, and INFO logs.
@@ -1028,7 +1485,7 @@ func Errorln(args ...interface{}) {
 // ErrorlnWithDepth is equivalent to Errorln but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
 func ErrorlnWithDepth(extraDepth int, args ...interface{}) {
-	logging.printlnWithDepth(errorLog, extraDepth, args...)
+	logging.printlnWithDepth(errorLog, extraDepth, nil, args...)
 }
 
 // Errorf logs to the ERROR, WARNING, and INFO logs.
@@ -1050,18 +1507,20 @@ func ErrorfIf(err error, format string, args ...interface{}) {
 // ErrorfWithDepth is equivalent to Errorf but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func ErrorfWithDepth(extraDepth int, format string, args ...interface{}) {
-	logging.printfWithDepth(errorLog, extraDepth, format, args...)
+	logging.printfWithDepth(errorLog, extraDepth, nil, format, args...)
 }
 
 // Fatal logs to the FATAL, ERROR, WARNING, and INFO logs,
-// including a stack trace of all running goroutines, then calls os.Exit(255).
+// including a stack trace of all running goroutines, then calls os.Exit(255)
+// or the function set by SetExitFunc.
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func Fatal(args ...interface{}) {
 	logging.print(fatalLog, args...)
 }
 
 // FatalIf logs to the FATAL, ERROR, WARNING, and INFO,
-// including a stack trace of all running goroutines, then calls os.Exit(255).
+// including a stack trace of all running goroutines, then calls os.Exit(255)
+// or the function set by SetExitFunc.
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func FatalIf(err error, args ...interface{}) {
 	if err != nil {
@@ -1078,11 +1537,12 @@ func FatalIf(err error, args ...interface{}) {
 // FatalWithDepth is equivalent to Fatal but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func FatalWithDepth(extraDepth int, args ...interface{}) {
-	logging.printWithDepth(fatalLog, extraDepth, args...)
+	logging.printWithDepth(fatalLog, extraDepth, nil, args...)
 }
 
 // Fatalln logs to the FATAL, ERROR, WARNING, and INFO logs,
-// including a stack trace of all running goroutines, then calls os.Exit(255).
+// including a stack trace of all running goroutines, then calls os.Exit(255)
+// or the function set by SetExitFunc.
 // Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
 func Fatalln(args ...interface{}) {
 	logging.println(fatalLog, args...)
@@ -1091,11 +1551,12 @@ func Fatalln(args ...interface{}) {
 // FatallnWithDepth is equivalent to Fatalln but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Println; a newline is appended if missing.
 func FatallnWithDepth(extraDepth int, args ...interface{}) {
-	logging.printlnWithDepth(fatalLog, extraDepth, args...)
+	logging.printlnWithDepth(fatalLog, extraDepth, nil, args...)
 }
 
 // Fatalf logs to the FATAL, ERROR, WARNING, and INFO logs,
-// including a stack trace of all running goroutines, then calls os.Exit(255).
+// including a stack trace of all running goroutines, then calls os.Exit(255)
+// or the function set by SetExitFunc.
 // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func Fatalf(format string, args ...interface{}) {
 	logging.printf(fatalLog, format, args...)
@@ -1104,14 +1565,15 @@ func Fatalf(format string, args ...interface{}) {
 // FatalfWithDepth is equivalent to Fatalf but with a specified extra depth (on the call stack).
 // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func FatalfWithDepth(extraDepth int, format string, args ...interface{}) {
-	logging.printfWithDepth(fatalLog, extraDepth, format, args...)
+	logging.printfWithDepth(fatalLog, extraDepth, nil, format, args...)
 }
 
 // fatalNoStacks is non-zero if we are to exit without dumping goroutine stacks.
 // It allows Exit and relatives to use the Fatal logs.
 var fatalNoStacks uint32
 
-// Exit logs to the FATAL, ERROR, WARNING, and INFO logs, then calls os.Exit(1).
+// Exit logs to the FATAL, ERROR, WARNING, and INFO logs, then calls os.Exit(1)
+// or the function set by SetExitFunc.
 // Arguments are handled in the manner of fmt.Print; a newline is appended if missing.
 func Exit(args ...interface{}) {
 	atomic.StoreUint32(&fatalNoStacks, 1)
@@ -1122,16 +1584,18 @@ func Exit(args ...interface{}) {
 // ExitWithDepth(0, "msg") is the same as Exit("msg").
 func ExitWithDepth(depth int, args ...interface{}) {
 	atomic.StoreUint32(&fatalNoStacks, 1)
-	logging.printWithDepth(fatalLog, depth, args...)
+	logging.printWithDepth(fatalLog, depth, nil, args...)
 }
 
-// Exitln logs to the FATAL, ERROR, WARNING, and INFO logs, then calls os.Exit(1).
+// Exitln logs to the FATAL, ERROR, WARNING, and INFO logs, then calls os.Exit(1)
+// or the function set by SetExitFunc.
 func Exitln(args ...interface{}) {
 	atomic.StoreUint32(&fatalNoStacks, 1)
 	logging.println(fatalLog, args...)
 }
 
-// Exitf logs to the FATAL, ERROR, WARNING, and INFO logs, then calls os.Exit(1).
+// Exitf logs to the FATAL, ERROR, WARNING, and INFO logs, then calls os.Exit(1)
+// or the function set by SetExitFunc.
 // Arguments are handled in the manner of fmt.Printf; a newline is appended if missing.
 func Exitf(format string, args ...interface{}) {
 	atomic.StoreUint32(&fatalNoStacks, 1)
@@ -0,0 +1,211 @@
+package glog
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncOverflow determines what happens to a log line when the asynchronous
+// output queue is full.
+type AsyncOverflow int
+
+const (
+	// AsyncBlock waits for space in the queue. This is the default.
+	AsyncBlock AsyncOverflow = iota
+	// AsyncDropNewest drops the line being logged.
+	AsyncDropNewest
+	// AsyncDropOldest drops the oldest line in the queue to make room.
+	AsyncDropOldest
+)
+
+// AsyncOptions configures asynchronous output.
+type AsyncOptions struct {
+	// QueueSize is the maximum number of lines waiting to be written.
+	// It defaults to 1024.
+	QueueSize int
+	// Overflow determines what happens when the queue is full.
+	Overflow AsyncOverflow
+	// MaxBatch is the maximum number of lines written to the output writer
+	// with a single call to Write. It defaults to 64.
+	MaxBatch int
+}
+
+// EnableAsync makes logging calls hand their output to a dedicated goroutine
+// instead of writing it themselves, so that a slow output writer does not
+// hold them up. Lines are written in order, in batches when they queue up.
+// Flush, and so Fatal, waits for the queue to drain.
+func EnableAsync(opts AsyncOptions) {
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = 64
+	}
+	a := newAsyncWriter(&logging, opts)
+
+	// The previous writer drains its queue before the new one takes over,
+	// so that lines are not written to an output by both at once, nor out
+	// of order.
+	logging.mu.Lock()
+	if logging.async != nil {
+		logging.async.close()
+	}
+	logging.async = a
+	logging.mu.Unlock()
+}
+
+// DisableAsync returns to writing output synchronously, once any queued
+// output has been written.
+func DisableAsync() {
+	logging.mu.Lock()
+	if logging.async != nil {
+		logging.async.close()
+	}
+	logging.async = nil
+	logging.mu.Unlock()
+}
+
+// asyncItem is a log line waiting to be written to w.
+type asyncItem struct {
+	w   *writer
+	buf *buffer
+}
+
+// asyncWriter queues log lines in a ring and writes them on its own
+// goroutine. It never takes l.mu, so l.mu may be held while waiting on it.
+type asyncWriter struct {
+	l    *loggingT
+	opts AsyncOptions
+
+	// mu protects the remaining fields; cond is signalled whenever
+	// any of them change.
+	mu      sync.Mutex
+	cond    *sync.Cond
+	ring    []asyncItem
+	head    int  // index of the oldest item in ring
+	n       int  // number of items in ring
+	busy    bool // a batch is being written
+	closed  bool
+	exiting bool // a write error is making the program exit
+	done    chan struct{}
+}
+
+func newAsyncWriter(l *loggingT, opts AsyncOptions) *asyncWriter {
+	a := &asyncWriter{
+		l:    l,
+		opts: opts,
+		ring: make([]asyncItem, opts.QueueSize),
+		done: make(chan struct{}),
+	}
+	a.cond = sync.NewCond(&a.mu)
+	go a.loop()
+	return a
+}
+
+// enqueue adds buf to the queue to be written to w, taking ownership of it.
+func (a *asyncWriter) enqueue(w *writer, buf *buffer) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for a.n == len(a.ring) {
+		switch a.opts.Overflow {
+		case AsyncDropNewest:
+			a.drop(buf)
+			return
+		case AsyncDropOldest:
+			a.drop(a.ring[a.head].buf)
+			a.ring[a.head] = asyncItem{}
+			a.head = (a.head + 1) % len(a.ring)
+			a.n--
+		default:
+			a.cond.Wait()
+		}
+	}
+	a.ring[(a.head+a.n)%len(a.ring)] = asyncItem{w, buf}
+	a.n++
+	a.cond.Broadcast()
+}
+
+// drop counts and releases a line that will not be written.
+// a.mu is held.
+func (a *asyncWriter) drop(buf *buffer) {
+	atomic.AddInt64(&Stats.AsyncDropped.lines, 1)
+	atomic.AddInt64(&Stats.AsyncDropped.bytes, int64(buf.Len()))
+	a.l.putBuffer(buf)
+}
+
+// wait returns once everything queued so far has been written.
+func (a *asyncWriter) wait() {
+	a.mu.Lock()
+	for a.n > 0 || a.busy {
+		a.cond.Wait()
+	}
+	a.mu.Unlock()
+}
+
+// close writes everything queued and stops the writer goroutine.
+func (a *asyncWriter) close() {
+	a.mu.Lock()
+	a.closed = true
+	a.cond.Broadcast()
+	a.mu.Unlock()
+	<-a.done
+}
+
+func (a *asyncWriter) loop() {
+	defer close(a.done)
+	var (
+		items []asyncItem
+		batch []byte
+	)
+	for {
+		a.mu.Lock()
+		for a.n == 0 && !a.closed {
+			a.cond.Wait()
+		}
+		if a.n == 0 {
+			a.mu.Unlock()
+			return
+		}
+		items = items[:0]
+		for a.n > 0 && len(items) < a.opts.MaxBatch {
+			items = append(items, a.ring[a.head])
+			a.ring[a.head] = asyncItem{}
+			a.head = (a.head + 1) % len(a.ring)
+			a.n--
+		}
+		a.busy = true
+		a.cond.Broadcast()
+		a.mu.Unlock()
+
+		// Write runs of lines for the same writer with a single call.
+		var err error
+		for i := 0; i < len(items); {
+			w := items[i].w
+			batch = batch[:0]
+			lines := 0
+			for ; i < len(items) && items[i].w == w; i++ {
+				batch = append(batch, items[i].buf.Bytes()...)
+				a.l.putBuffer(items[i].buf)
+				lines++
+			}
+			if _, werr := a.l.write(w, batch, lines); werr != nil {
+				err = werr
+			}
+		}
+
+		exit := err != nil && getWriteErrorPolicy() == WriteErrorExit
+		a.mu.Lock()
+		a.busy = false
+		if exit {
+			exit = !a.exiting
+			a.exiting = true
+		}
+		a.cond.Broadcast()
+		a.mu.Unlock()
+		if exit {
+			// Exiting flushes, which waits for this goroutine to write
+			// what is queued, so it must happen on another.
+			go a.l.exit(err)
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package glog
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// gatedWriter blocks writes until its gate is closed, and records them.
+type gatedWriter struct {
+	gate chan struct{}
+
+	mu     sync.Mutex
+	writes int
+	buf    bytes.Buffer
+}
+
+func (w *gatedWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.writes++
+	return w.buf.Write(p)
+}
+
+func TestAsync(t *testing.T) {
+	defer resetOutput(setBuffer())
+	EnableAsync(AsyncOptions{})
+	defer DisableAsync()
+
+	for i := 0; i < 100; i++ {
+		Infof("async line %d", i)
+	}
+	Flush()
+
+	out := contents()
+	last := -1
+	for i := 0; i < 100; i++ {
+		at := strings.Index(out, fmt.Sprintf("async line %d\n", i))
+		if at < last {
+			t.Fatalf("line %d missing or out of order: %q", i, out)
+		}
+		last = at
+	}
+}
+
+func TestAsyncBatches(t *testing.T) {
+	defer SetOutput(os.Stdout)
+	w := &gatedWriter{gate: make(chan struct{})}
+	SetOutput(w)
+	EnableAsync(AsyncOptions{MaxBatch: 10})
+	defer DisableAsync()
+
+	for i := 0; i < 20; i++ {
+		Info("batched")
+	}
+	close(w.gate)
+	Flush()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if got := strings.Count(w.buf.String(), "batched"); got != 20 {
+		t.Errorf("got %d lines, want 20", got)
+	}
+	if w.writes >= 20 || w.writes < 2 {
+		t.Errorf("got %d writes for 20 lines in batches of 10", w.writes)
+	}
+}
+
+func TestAsyncDropNewest(t *testing.T) {
+	defer SetOutput(os.Stdout)
+	w := &gatedWriter{gate: make(chan struct{})}
+	SetOutput(w)
+	EnableAsync(AsyncOptions{QueueSize: 2, Overflow: AsyncDropNewest})
+	defer DisableAsync()
+
+	dropped := Stats.AsyncDropped.Lines()
+	for i := 0; i < 10; i++ {
+		Info("dropped")
+	}
+	if Stats.AsyncDropped.Lines() == dropped {
+		t.Error("no lines were dropped")
+	}
+	close(w.gate)
+}
+
+func TestAsyncFatal(t *testing.T) {
+	defer resetOutput(setBuffer())
+	EnableAsync(AsyncOptions{})
+	defer DisableAsync()
+
+	var written bool
+	defer SetExitFunc(SetExitFunc(func(int) {
+		written = strings.Contains(contents(), "async fatal")
+	}))
+	Fatal("async fatal")
+
+	if !written {
+		t.Error("Fatal exited before its output was written")
+	}
+}
+
+// gatedFailingWriter is a failingWriter that blocks writes until its gate
+// is closed.
+type gatedFailingWriter struct {
+	gate chan struct{}
+	failingWriter
+}
+
+func (w *gatedFailingWriter) Write(p []byte) (int, error) {
+	<-w.gate
+	return w.failingWriter.Write(p)
+}
+
+func TestAsyncWriteErrorExit(t *testing.T) {
+	defer SetOutput(os.Stdout)
+	defer SetWriteErrorPolicy(WriteErrorIgnore)
+	SetWriteErrorPolicy(WriteErrorExit)
+	w := &gatedFailingWriter{gate: make(chan struct{}), failingWriter: failingWriter{failures: 1}}
+	SetOutput(w)
+	EnableAsync(AsyncOptions{MaxBatch: 1})
+	defer DisableAsync()
+
+	exited := make(chan int, 1)
+	defer SetExitFunc(SetExitFunc(func(c int) { exited <- c }))
+	// The second line is still queued when the first fails to be written.
+	Info("exit")
+	Info("queued")
+	close(w.gate)
+
+	select {
+	case code := <-exited:
+		if code != ExitCodeWriteError {
+			t.Errorf("got exit code %d, want %d", code, ExitCodeWriteError)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("write error did not make the program exit in time")
+	}
+}
+
+func TestAsyncWriteErrorsCountLines(t *testing.T) {
+	defer SetOutput(os.Stdout)
+	w := &gatedFailingWriter{gate: make(chan struct{}), failingWriter: failingWriter{failures: 100}}
+	SetOutput(w)
+	EnableAsync(AsyncOptions{})
+	defer DisableAsync()
+
+	// The first line is being written while the others queue up, to be
+	// written, and fail, together.
+	errors := Stats.WriteErrors.Lines()
+	for i := 0; i < 10; i++ {
+		Info("failed")
+	}
+	close(w.gate)
+	Flush()
+	if got := Stats.WriteErrors.Lines() - errors; got != 10 {
+		t.Errorf("counted %d lines as write errors, want 10", got)
+	}
+}
+
+// overlapWriter is a gatedWriter that records whether two writes were
+// ever in progress at once.
+type overlapWriter struct {
+	gatedWriter
+	active  int32
+	overlap int32
+}
+
+func (w *overlapWriter) Write(p []byte) (int, error) {
+	if atomic.AddInt32(&w.active, 1) > 1 {
+		atomic.StoreInt32(&w.overlap, 1)
+	}
+	defer atomic.AddInt32(&w.active, -1)
+	return w.gatedWriter.Write(p)
+}
+
+func TestDisableAsyncDrainsFirst(t *testing.T) {
+	defer SetOutput(os.Stdout)
+	w := &overlapWriter{gatedWriter: gatedWriter{gate: make(chan struct{})}}
+	SetOutput(w)
+	EnableAsync(AsyncOptions{MaxBatch: 1})
+
+	for i := 0; i < 10; i++ {
+		Infof("queued %d", i)
+	}
+	disabled := make(chan struct{})
+	go func() {
+		DisableAsync()
+		close(disabled)
+	}()
+	time.AfterFunc(20*time.Millisecond, func() { close(w.gate) })
+	time.Sleep(10 * time.Millisecond)
+	Info("synchronous")
+	<-disabled
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	out := w.buf.String()
+	if strings.Index(out, "synchronous") < strings.Index(out, "queued 9") {
+		t.Errorf("synchronous line written before the queue drained: %q", out)
+	}
+	if atomic.LoadInt32(&w.overlap) != 0 {
+		t.Error("queued and synchronous lines were written at once")
+	}
+}
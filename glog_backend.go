@@ -1,16 +1,41 @@
 package glog
 
 import (
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"reflect"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 var (
-	messageChan   = make(chan Event, 10)
-	backendChans  []chan<- Event
+	backendChans  []backendChan
 	backendChanMu sync.RWMutex
+	// backendCount is the number of registered backends, so that logging
+	// calls can check for them cheaply. It is read with atomic.LoadInt32.
+	backendCount int32
 )
 
+// backendChan is a registered backend's channel and the filters that
+// decide which events are sent on it.
+type backendChan struct {
+	c       chan<- Event
+	filters []BackendFilter
+}
+
+// backendStats counts events delivered to backends, events dropped
+// because a backend was full, and errors returned by Backend.Handle.
+// Values must be read with atomic.LoadInt64.
+var backendStats struct {
+	delivered, dropped, failed int64
+}
+
 type data struct {
 	d interface{}
 }
@@ -33,6 +58,31 @@ type Event struct {
 	Message    []byte
 	Data       []interface{}
 	StackTrace []uintptr // inner to outer
+	Time       time.Time
+	// PC is the program counter of the logging call, as returned by
+	// runtime.Callers.
+	PC uintptr
+	// Fingerprint identifies events that report the same problem, so that
+	// backends can group them. It is derived from the call site, the format
+	// string and the type of the root cause of any error.
+	Fingerprint string
+	// Prefix is the prefix of the Logger that logged the event, if any.
+	Prefix string
+	// Body is the message as it follows the header of a line written by
+	// ClassicEncoder, whatever the encoder in use, without the final
+	// newline. Backends that send the header's fields on their own use it
+	// in place of Message, or Message itself if Body is empty, as it may
+	// be for events made with NewEvent.
+	Body string
+}
+
+// text returns e.Body, or e.Message without the final newline if Body is
+// empty.
+func (e Event) text() string {
+	if e.Body != "" {
+		return e.Body
+	}
+	return strings.TrimSuffix(string(e.Message), "\n")
 }
 
 // NewEvent creates a glog.Event from the logged event's severity,
@@ -42,24 +92,66 @@ type Event struct {
 // in Event.Data.
 func NewEvent(s severity, message []byte, dataArgs []interface{}, extraDepth int) Event {
 	var stackTrace []uintptr
+	var pc uintptr
 
 	if s >= errorLog {
 		callers := make([]uintptr, 20)
 		written := runtime.Callers(4+extraDepth, callers)
 		stackTrace = callers[:written]
+		if written > 0 {
+			pc = stackTrace[0]
+		}
+	} else {
+		var pcs [1]uintptr
+		if runtime.Callers(4+extraDepth, pcs[:]) > 0 {
+			pc = pcs[0]
+		}
 	}
 
 	return Event{
-		Severity:   severityName[s],
-		Message:    message,
-		Data:       dataArgs,
-		StackTrace: stackTrace,
+		Severity:    severityName[s],
+		Message:     message,
+		Data:        dataArgs,
+		StackTrace:  stackTrace,
+		Time:        timeNow(),
+		PC:          pc,
+		Fingerprint: eventFingerprint(pc, dataArgs),
 	}
 }
 
+// eventFingerprint returns a fingerprint for an event logged at pc with
+// the given data. It is a hash of the function, file name and line of the
+// logging call, the format string from any FormatStringArg and the type
+// of the root cause of the first ErrorArg. It leaves out the message, so
+// that events that differ only in the values logged share a fingerprint.
+func eventFingerprint(pc uintptr, data []interface{}) string {
+	h := fnv.New64a()
+	if pc != 0 {
+		site := siteForPC(pc)
+		fmt.Fprintf(h, "%s\x00%s:%d\x00", site.function, filepath.Base(site.file), site.line)
+	}
+	var format, errorType bool
+	for _, d := range data {
+		switch d := d.(type) {
+		case FormatStringArg:
+			if !format {
+				format = true
+				fmt.Fprintf(h, "format\x00%s\x00", d.Format)
+			}
+		case ErrorArg:
+			if !errorType && d.Error != nil {
+				errorType = true
+				fmt.Fprintf(h, "error\x00%T\x00", d.RootCause())
+			}
+		}
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
 // filterData splits out any items tagged by Data() and returns two slices:
 // the first with only argments meant for the log call and the second with
-// only arguments meant to passed to any registered backends.
+// only arguments meant to passed to any registered backends. Redactors in
+// the second are replaced by their redacted form.
 func filterData(args []interface{}) ([]interface{}, []interface{}) {
 	var (
 		realArgs []interface{}
@@ -68,61 +160,216 @@ func filterData(args []interface{}) ([]interface{}, []interface{}) {
 
 	for _, arg := range args {
 		if argd, ok := arg.(data); ok {
-			dataArgs = append(dataArgs, argd.d)
+			dataArgs = append(dataArgs, redact(argd.d))
 		} else {
 			realArgs = append(realArgs, arg)
 			// PATCH(jwoglom): Propagate an error type passed directly to
 			// glog as an implicit glog.ErrorArg
 			if errarg, ok := arg.(error); ok {
-				dataArgs = append(dataArgs, ErrorArg{errarg})
+				dataArgs = append(dataArgs, redact(ErrorArg{errarg}))
 			}
 		}
 	}
 	return realArgs, dataArgs
 }
 
+// dataField is a key-value pair derived from an item of Event.Data, for
+// backends that need data in that form.
+type dataField struct {
+	key   string
+	value string
+}
+
+// dataFields converts data items into key-value pairs. An ErrorArg gives
+// "error" and "root_cause", a FormatStringArg gives "format", a
+// TruncatedData gives "truncated", a TraceContext gives "trace_id" and
+// "span_id", and a map with string keys gives one pair per entry.
+// Anything else is formatted with fmt.Sprint and keyed by its position,
+// as in "data0". Keys are made unique by adding a suffix where necessary.
+func dataFields(data []interface{}) []dataField {
+	var fields []dataField
+	seen := make(map[string]int)
+	add := func(key, value string) {
+		if n := seen[key]; n > 0 {
+			seen[key] = n + 1
+			key += "_" + strconv.Itoa(n)
+		} else {
+			seen[key] = 1
+		}
+		fields = append(fields, dataField{key, value})
+	}
+	for i, d := range data {
+		switch d := d.(type) {
+		case ErrorArg:
+			if d.Error == nil {
+				continue
+			}
+			add("error", d.Error.Error())
+			if root := d.RootCause(); root != nil {
+				add("root_cause", root.Error())
+			}
+		case FormatStringArg:
+			add("format", d.Format)
+		case TruncatedData:
+			add("truncated", d.String())
+		case TraceContext:
+			add("trace_id", d.TraceID)
+			add("span_id", d.SpanID)
+		case map[string]string:
+			keys := make([]string, 0, len(d))
+			for k := range d {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				add(k, d[k])
+			}
+		case map[string]interface{}:
+			keys := make([]string, 0, len(d))
+			for k := range d {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				add(k, fmt.Sprint(d[k]))
+			}
+		default:
+			add("data"+strconv.Itoa(i), fmt.Sprint(d))
+		}
+	}
+	return fields
+}
+
+// splitArgs is like filterData, but also reports whether there are any
+// registered backends to send an event to. If not, and keepData is false,
+// it returns no data and avoids allocating unless args contains items
+// tagged by Data() that must be removed.
+func splitArgs(args []interface{}, keepData bool) (realArgs, dataArgs []interface{}, send bool) {
+	send = hasBackends()
+	if send || keepData {
+		realArgs, dataArgs = filterData(args)
+		return realArgs, dataArgs, send
+	}
+	for _, arg := range args {
+		if _, ok := arg.(data); ok {
+			realArgs, _ = filterData(args)
+			return realArgs, nil, false
+		}
+	}
+	return args, nil, false
+}
+
+// hasBackends reports whether any backends are registered.
+func hasBackends() bool {
+	return atomic.LoadInt32(&backendCount) > 0
+}
+
+// A BackendFilter reports whether an event should be sent to a backend.
+type BackendFilter func(Event) bool
+
+// MinSeverity returns a BackendFilter that accepts events of the given
+// severity ("INFO", "WARNING", "ERROR" or "FATAL") or above.
+func MinSeverity(name string) BackendFilter {
+	min, ok := severityByName(strings.ToUpper(name))
+	if !ok {
+		panic(fmt.Sprintf("glog: unknown severity %q", name))
+	}
+	return func(e Event) bool {
+		s, ok := severityByName(e.Severity)
+		return ok && s >= min
+	}
+}
+
+// HasData returns a BackendFilter that accepts events with an item in
+// Event.Data of the same type as example, such as:
+//
+//	glog.RegisterBackend(glog.HasData(AuditRecord{}))
+//
+// Items that are Redactors have already been replaced with Redacted.
+func HasData(example interface{}) BackendFilter {
+	typ := reflect.TypeOf(example)
+	return func(e Event) bool {
+		for _, d := range e.Data {
+			if reflect.TypeOf(d) == typ {
+				return true
+			}
+		}
+		return false
+	}
+}
+
 // RegisterBackend returns a channel on which Event's will be passed
-// when they are logged.
+// when they are logged. If filters are given, only events accepted by
+// all of them are passed.
+//
+// Events are passed without blocking; if the channel is full, the event
+// is dropped for that backend. Filters are evaluated first, so events a
+// backend does not want take no space in its channel. They are called
+// from the logging goroutine, and so must be fast and must not log.
 //
 // The caller is responsible for any necessary synchronization such
 // that the call to this function "happens before" any events to be
 // logged to this channel or other calls to RegisterBackend().
-func RegisterBackend() <-chan Event {
+func RegisterBackend(filters ...BackendFilter) <-chan Event {
+	c := make(chan Event, 100)
+	addBackendChan(c, filters)
+	return c
+}
+
+func addBackendChan(c chan<- Event, filters []BackendFilter) {
 	backendChanMu.Lock()
 	defer backendChanMu.Unlock()
-	if len(backendChans) == 0 {
-		go broadcastEvents()
-	}
+	// Copy on write, so that eventForBackends can use the slice it reads
+	// without holding the lock.
+	chans := make([]backendChan, len(backendChans), len(backendChans)+1)
+	copy(chans, backendChans)
+	backendChans = append(chans, backendChan{c, filters})
+	atomic.AddInt32(&backendCount, 1)
+}
 
-	c := make(chan Event, 100)
-	backendChans = append(backendChans, c)
-	return c
+// removeBackendChan stops events being sent on c. Events may still be sent
+// by logging calls that are already under way.
+func removeBackendChan(c chan<- Event) {
+	backendChanMu.Lock()
+	defer backendChanMu.Unlock()
+	chans := make([]backendChan, 0, len(backendChans))
+	for _, b := range backendChans {
+		if b.c == c {
+			atomic.AddInt32(&backendCount, -1)
+		} else {
+			chans = append(chans, b)
+		}
+	}
+	backendChans = chans
 }
 
-// eventForBackends creates and writes a glog.Event to the message channel
-// if and only if we have registered backends.
+// eventForBackends passes e to each registered backend whose filters
+// accept it.
 func eventForBackends(e Event) {
+	if !hasBackends() {
+		return
+	}
 	backendChanMu.RLock()
-	hasBackends := len(backendChans) > 0
+	backendChans := backendChans
 	backendChanMu.RUnlock()
-	if hasBackends {
+	for _, b := range backendChans {
+		if !b.accepts(e) {
+			continue
+		}
 		select {
-		case messageChan <- e:
+		case b.c <- e:
+			atomic.AddInt64(&backendStats.delivered, 1)
 		default:
+			atomic.AddInt64(&backendStats.dropped, 1)
 		}
 	}
 }
 
-func broadcastEvents() {
-	for e := range messageChan {
-		backendChanMu.RLock()
-		backendChans := backendChans
-		backendChanMu.RUnlock()
-		for _, c := range backendChans {
-			select {
-			case c <- e:
-			default:
-			}
+func (b backendChan) accepts(e Event) bool {
+	for _, f := range b.filters {
+		if !f(e) {
+			return false
 		}
 	}
+	return true
 }
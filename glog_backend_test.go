@@ -117,6 +117,96 @@ func TestErrorArgs(t *testing.T) {
 	waitForData(t, comm, err.Error(), ErrorArg{err})
 }
 
+type auditRecord struct {
+	user string
+}
+
+func TestBackendFilters(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	errors := RegisterBackend(MinSeverity("error"))
+	audit := RegisterBackend(HasData(auditRecord{}))
+	custom := RegisterBackend(func(e Event) bool {
+		return strings.Contains(string(e.Message), "custom")
+	}, MinSeverity("WARNING"))
+
+	Info("filter info custom")
+	Info("filter info audit", Data(auditRecord{"alice"}))
+	Warning("filter warning custom")
+	Error("filter error")
+
+	expect := func(name string, c <-chan Event, want ...string) {
+		t.Helper()
+		var got []string
+		for len(c) > 0 {
+			e := <-c
+			msg := strings.SplitN(string(e.Message), "] ", 2)[1]
+			got = append(got, strings.TrimSuffix(msg, "\n"))
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Errorf("%s backend got %q, want %q", name, got, want)
+		}
+	}
+	expect("errors", errors, "filter error")
+	expect("audit", audit, "filter info audit")
+	expect("custom", custom, "filter warning custom")
+}
+
+func TestMinSeverityUnknown(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("MinSeverity did not panic for an unknown severity")
+		}
+	}()
+	MinSeverity("LOUD")
+}
+
+type otherError struct{}
+
+func (otherError) Error() string { return "other error" }
+
+func TestFingerprint(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	comm := RegisterBackend()
+	logf := func(format string, args ...interface{}) Event {
+		Errorf(format, args...)
+		return <-comm
+	}
+	fp := func(format string, args ...interface{}) string {
+		return logf(format, args...).Fingerprint
+	}
+
+	e := logf("fingerprint %v", 1)
+	if e.Fingerprint == "" {
+		t.Fatal("event has no fingerprint")
+	}
+	if site := siteForPC(e.PC); !strings.HasSuffix(site.function, "TestFingerprint.func1") {
+		t.Errorf("PC is in %s, want the logging call", site.function)
+	}
+	if got := fp("fingerprint %v", 2); got != e.Fingerprint {
+		t.Errorf("fingerprint changed with the values logged: %s != %s", got, e.Fingerprint)
+	}
+	if got := fp("fingerprint %v!", 1); got == e.Fingerprint {
+		t.Error("fingerprint did not change with the format string")
+	}
+	if fp("%v", errors.New("a")) != fp("%v", errors.New("b")) {
+		t.Error("fingerprint changed with the error message")
+	}
+	if fp("%v", errors.New("a")) == fp("%v", otherError{}) {
+		t.Error("fingerprint did not change with the error type")
+	}
+
+	Errorf("fingerprint %v", 1)
+	if got := (<-comm).Fingerprint; got == e.Fingerprint {
+		t.Error("fingerprint did not change with the call site")
+	}
+	Info("fingerprint")
+	if got := <-comm; got.PC == 0 || got.Fingerprint == "" {
+		t.Errorf("INFO event has PC %#x and fingerprint %q", got.PC, got.Fingerprint)
+	}
+}
+
 func waitForData(t *testing.T, comm <-chan Event, expectedMessage string, expectedData ...interface{}) {
 	timeout := time.After(1 * time.Second)
 	for {
@@ -146,6 +236,25 @@ func waitForData(t *testing.T, comm <-chan Event, expectedMessage string, expect
 	}
 }
 
+func TestEventBody(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetEncoder(SetEncoder(ClassicEncoder))
+
+	comm := RegisterBackend()
+	for _, enc := range []Encoder{ClassicEncoder, LogfmtEncoder, JSONEncoder} {
+		SetEncoder(enc)
+		WithPrefix("[p]").Errorf("body %d\n", 1)
+		if e := <-comm; e.Body != "[p] body 1" {
+			t.Errorf("with %T, got body %q, want %q", enc, e.Body, "[p] body 1")
+		}
+		// The body is not cut short by text that looks like a header.
+		Error("E1018 12:00:00.000000 file.go:1] body")
+		if e := <-comm; e.Body != "E1018 12:00:00.000000 file.go:1] body" {
+			t.Errorf("with %T, got body %q", enc, e.Body)
+		}
+	}
+}
+
 func BenchmarkError(b *testing.B) {
 	defer resetOutput(setBuffer())
 	for i := 0; i < b.N; i++ {
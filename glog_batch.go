@@ -0,0 +1,125 @@
+package glog
+
+import (
+	"context"
+	"time"
+)
+
+// batcher collects events into batches on its own goroutine, and passes
+// each batch to send once it reaches a given size or age. A flush calls
+// send even if the batch is empty, so that it can retry earlier batches
+// it kept, and returns its error. The context
+// given to send is done once the batcher is closing, or the flush that
+// asked for the batch gives up, so that send can stop waiting to retry;
+// the last batch, sent on closing, may be retried in full.
+type batcher struct {
+	size     int
+	interval time.Duration
+	send     func(ctx context.Context, events []Event) error
+
+	// events is unbuffered, so that once add returns the event is part
+	// of a batch, and a subsequent flush will send it.
+	events  chan Event
+	flushes chan flushRequest
+	done    chan struct{}
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// flushRequest asks the batcher to send the current batch, and receives
+// the result of sending it.
+type flushRequest struct {
+	ctx    context.Context
+	result chan error
+}
+
+func newBatcher(size int, interval time.Duration, send func(context.Context, []Event) error) *batcher {
+	b := &batcher{
+		size:     size,
+		interval: interval,
+		send:     send,
+		events:   make(chan Event),
+		flushes:  make(chan flushRequest),
+		done:     make(chan struct{}),
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
+	go b.loop()
+	return b
+}
+
+// add adds e to the current batch.
+func (b *batcher) add(e Event) {
+	b.events <- e
+}
+
+// flush sends the current batch, and returns the error from sending it
+// once it has been sent, or ctx.Err() if ctx is done first.
+func (b *batcher) flush(ctx context.Context) error {
+	req := flushRequest{ctx, make(chan error, 1)}
+	select {
+	case b.flushes <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close sends the current batch, if any, and stops the batcher. A send in
+// progress stops retrying first.
+func (b *batcher) close() {
+	b.cancel()
+	close(b.events)
+	<-b.done
+}
+
+func (b *batcher) loop() {
+	defer close(b.done)
+	var (
+		batch []Event
+		timer *time.Timer
+		timeC <-chan time.Time
+	)
+	sendBatch := func(ctx context.Context, always bool) error {
+		if timer != nil {
+			timer.Stop()
+			timer, timeC = nil, nil
+		}
+		if len(batch) == 0 && !always {
+			return nil
+		}
+		err := b.send(ctx, batch)
+		batch = nil
+		return err
+	}
+	for {
+		select {
+		case e, ok := <-b.events:
+			if !ok {
+				sendBatch(context.Background(), false)
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= b.size {
+				sendBatch(b.ctx, false)
+			} else if timer == nil {
+				timer = time.NewTimer(b.interval)
+				timeC = timer.C
+			}
+		case <-timeC:
+			timer, timeC = nil, nil
+			sendBatch(b.ctx, false)
+		case req := <-b.flushes:
+			ctx, cancel := context.WithCancel(req.ctx)
+			stop := context.AfterFunc(b.ctx, cancel)
+			req.result <- sendBatch(ctx, true)
+			stop()
+			cancel()
+		}
+	}
+}
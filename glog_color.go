@@ -0,0 +1,131 @@
+package glog
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// ColorMode selects when the output is colored.
+type ColorMode int32
+
+const (
+	// ColorAuto colors the output when it is a terminal, unless the
+	// NO_COLOR environment variable was set to a non-empty value when
+	// the output was set.
+	ColorAuto ColorMode = iota
+	// ColorAlways colors the output, wherever it goes.
+	ColorAlways
+	// ColorNever never colors the output.
+	ColorNever
+)
+
+var colorModeNames = []string{
+	ColorAuto:   "auto",
+	ColorAlways: "always",
+	ColorNever:  "never",
+}
+
+// colorMode is the current ColorMode. Use atomic ops.
+var colorMode int32
+
+func init() {
+	flag.Var(new(ColorMode), "log_color", "when to color the severity and location of log lines: auto, always or never")
+}
+
+// SetColor sets when the severity letter and file:line of the classic
+// header are colored with ANSI escape codes, and returns the previous
+// mode. Coloring happens as lines are written to the output, so backends
+// always receive uncolored events.
+func SetColor(mode ColorMode) ColorMode {
+	return ColorMode(atomic.SwapInt32(&colorMode, int32(mode)))
+}
+
+// String implements flag.Value. The flag sets the mode for the program,
+// whatever the value of the ColorMode.
+func (m *ColorMode) String() string {
+	mode := atomic.LoadInt32(&colorMode)
+	if mode < 0 || int(mode) >= len(colorModeNames) {
+		return fmt.Sprint(mode)
+	}
+	return colorModeNames[mode]
+}
+
+// Set implements flag.Value.
+func (m *ColorMode) Set(value string) error {
+	for mode, name := range colorModeNames {
+		if name == value {
+			*m = ColorMode(mode)
+			SetColor(*m)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown color mode %q", value)
+}
+
+// isTerminal reports whether w is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// autoColor reports whether lines written to w are to be colored with
+// ColorAuto. It is checked once, when a writer is made for w, as
+// os.Getenv is too slow to run for every line.
+func autoColor(w io.Writer) bool {
+	return isTerminal(w) && os.Getenv("NO_COLOR") == ""
+}
+
+// colored reports whether lines written to w are to be colored.
+func (w *writer) colored() bool {
+	switch ColorMode(atomic.LoadInt32(&colorMode)) {
+	case ColorAlways:
+		return true
+	case ColorAuto:
+		return w.autoColor
+	}
+	return false
+}
+
+const colorReset = "\x1b[0m"
+
+// severityColor holds the escape code that starts the color for each
+// severity letter.
+var severityColor = []string{
+	infoLog:    "\x1b[32m",   // green
+	warningLog: "\x1b[33m",   // yellow
+	errorLog:   "\x1b[31m",   // red
+	fatalLog:   "\x1b[1;31m", // bold red
+}
+
+// locationColor starts the color for file:line.
+const locationColor = "\x1b[36m" // cyan
+
+// colorize returns a buffer holding the line in buf with the severity and
+// file:line colored, and releases buf. Lines without a classic header are
+// returned as they are.
+func (l *loggingT) colorize(buf *buffer) *buffer {
+	r := &buf.rec
+	if r.locEnd == 0 || r.locEnd > buf.Len() {
+		return buf
+	}
+	line := buf.Bytes()
+	c := l.getBuffer()
+	c.rec = *r
+	c.WriteString(severityColor[r.s])
+	c.WriteByte(line[0])
+	c.WriteString(colorReset)
+	c.Write(line[1:r.locStart])
+	c.WriteString(locationColor)
+	c.Write(line[r.locStart:r.locEnd])
+	c.WriteString(colorReset)
+	c.Write(line[r.locEnd:])
+	l.putBuffer(buf)
+	return c
+}
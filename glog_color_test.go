@@ -0,0 +1,75 @@
+package glog
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestColor(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetColor(SetColor(ColorAlways))
+
+	c := make(chan Event, 10)
+	b := ChanBackend(c)
+	AddBackend(b)
+	defer RemoveBackend(testContext(t), b)
+
+	Warning("careful")
+	line := contents()
+	if !strings.HasPrefix(line, "\x1b[33mW\x1b[0m") {
+		t.Errorf("severity not colored: %q", line)
+	}
+	if !strings.Contains(line, "\x1b[36mglog_color_test.go:") || !strings.Contains(line, "\x1b[0m] careful\n") {
+		t.Errorf("location not colored: %q", line)
+	}
+	FlushBackends(testContext(t))
+	if e := <-c; strings.Contains(string(e.Message), "\x1b") {
+		t.Errorf("event colored: %q", e.Message)
+	}
+
+	fakeStdout.Reset()
+	SetColor(ColorNever)
+	Warning("careful")
+	if strings.Contains(contents(), "\x1b") {
+		t.Errorf("colored with ColorNever: %q", contents())
+	}
+
+	// A bytes.Buffer is not a terminal.
+	fakeStdout.Reset()
+	SetColor(ColorAuto)
+	Warning("careful")
+	if strings.Contains(contents(), "\x1b") {
+		t.Errorf("colored with ColorAuto to a buffer: %q", contents())
+	}
+}
+
+func TestColorAuto(t *testing.T) {
+	defer SetColor(SetColor(ColorAuto))
+	if !(&writer{Writer: os.Stderr, autoColor: true}).colored() {
+		t.Error("terminal not colored")
+	}
+	if (&writer{Writer: os.Stderr}).colored() {
+		t.Error("colored without autoColor")
+	}
+
+	defer os.Setenv("NO_COLOR", os.Getenv("NO_COLOR"))
+	os.Setenv("NO_COLOR", "1")
+	if newWriter(os.Stderr).autoColor {
+		t.Error("terminal colored with NO_COLOR set")
+	}
+}
+
+func TestColorFlag(t *testing.T) {
+	defer SetColor(SetColor(ColorAuto))
+	var m ColorMode
+	if err := m.Set("always"); err != nil || m.String() != "always" {
+		t.Errorf("Set(always) gave error %v, mode %s", err, m.String())
+	}
+	if err := m.Set("sometimes"); err == nil {
+		t.Error("Set(sometimes) did not fail")
+	}
+	if m.String() != "always" {
+		t.Errorf("mode changed to %s after an error", m.String())
+	}
+}
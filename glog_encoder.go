@@ -0,0 +1,270 @@
+package glog
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+	"unicode/utf8"
+)
+
+// An Encoder renders log lines. The classic encoder writes the header
+// described at header followed by the message; others render the parts
+// of a logging call as fields.
+type Encoder interface {
+	// header writes the part of the line before the message, from buf.rec.
+	header(buf *buffer)
+	// finish completes the line once the message, which starts at start,
+	// has been written. It does not add the final newline.
+	finish(buf *buffer, start int)
+	// structured reports whether the encoder renders the prefix and data
+	// of a Logger as fields, rather than as part of the message.
+	structured() bool
+}
+
+// record is what an encoder knows of a logging call, apart from the
+// message itself.
+type record struct {
+	s        severity
+	time     time.Time
+	file     string // full path
+	line     int
+	function string
+	prefix   string
+	data     []interface{}
+	// locStart and locEnd are the offsets of file:line in a classic
+	// header, for coloring. locEnd is zero if there is no such header.
+	locStart, locEnd int
+}
+
+var (
+	// ClassicEncoder writes lines in the format described at header.
+	// It is the default.
+	ClassicEncoder Encoder = classicEncoder{}
+	// LogfmtEncoder writes lines in the logfmt format:
+	//
+	//	ts=2006-01-02T15:04:05.000000-07:00 level=info caller=file.go:12 prefix="..." msg="..." key="value"
+	//
+	// The prefix is a Logger's, and appears only if it is set. Items tagged
+	// by Data, including a Logger's, follow as keys and values as for
+	// backends; errors logged add "error" and "root_cause" keys.
+	LogfmtEncoder Encoder = logfmtEncoder{}
+	// JSONEncoder writes each line as a JSON object, with the same keys
+	// as LogfmtEncoder:
+	//
+	//	{"ts":"2006-01-02T15:04:05.000000-07:00","level":"info","caller":"file.go:12","prefix":"...","msg":"...","key":"value"}
+	JSONEncoder Encoder = jsonEncoder{}
+)
+
+// encoderValue holds the current Encoder, in an encoderHolder.
+var encoderValue atomic.Value
+
+// encoderHolder lets encoders of different types be kept in encoderValue.
+type encoderHolder struct {
+	Encoder
+}
+
+func init() {
+	encoderValue.Store(encoderHolder{ClassicEncoder})
+	flag.Var(encoderFlag{}, "log_format", "format of log lines: classic, logfmt or json")
+}
+
+// SetEncoder sets the encoder used for all log lines, and returns the
+// previous one. Passing nil restores ClassicEncoder.
+func SetEncoder(e Encoder) Encoder {
+	if e == nil {
+		e = ClassicEncoder
+	}
+	return encoderValue.Swap(encoderHolder{e}).(encoderHolder).Encoder
+}
+
+func currentEncoder() Encoder {
+	return encoderValue.Load().(encoderHolder).Encoder
+}
+
+// encoderNames maps the values of the -log_format flag to encoders.
+var encoderNames = map[string]Encoder{
+	"classic": ClassicEncoder,
+	"logfmt":  LogfmtEncoder,
+	"json":    JSONEncoder,
+}
+
+// encoderFlag is the flag.Value for -log_format.
+type encoderFlag struct{}
+
+func (encoderFlag) String() string {
+	if encoderValue.Load() == nil {
+		return "classic"
+	}
+	e := currentEncoder()
+	for name, enc := range encoderNames {
+		if enc == e {
+			return name
+		}
+	}
+	return fmt.Sprintf("%T", e)
+}
+
+func (encoderFlag) Set(value string) error {
+	e, ok := encoderNames[value]
+	if !ok {
+		return fmt.Errorf("unknown log format %q", value)
+	}
+	SetEncoder(e)
+	return nil
+}
+
+// logfmtLevel holds the value of the level key for each severity.
+var logfmtLevel = []string{
+	infoLog:    "info",
+	warningLog: "warning",
+	errorLog:   "error",
+	fatalLog:   "fatal",
+}
+
+type logfmtEncoder struct{}
+
+// timestampFormat is the format of the times written by the structured
+// encoders.
+const timestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+func (logfmtEncoder) header(buf *buffer) {
+	r := &buf.rec
+	buf.WriteString("ts=")
+	buf.Write(r.time.AppendFormat(buf.tmp[:0], timestampFormat))
+	buf.WriteString(" level=")
+	buf.WriteString(logfmtLevel[r.s])
+	buf.WriteString(" caller=")
+	buf.WriteString(PathBase.format(r.file))
+	buf.tmp[0] = ':'
+	n := buf.someDigits(1, r.line)
+	buf.Write(buf.tmp[:n+1])
+	if r.prefix != "" {
+		buf.WriteString(" prefix=")
+		logfmtQuote(buf, r.prefix)
+	}
+	buf.WriteString(" msg=")
+}
+
+// finish quotes the message, and adds the data.
+func (logfmtEncoder) finish(buf *buffer, start int) {
+	quoteMessage(buf, start)
+	for _, f := range dataFields(buf.rec.data) {
+		buf.WriteByte(' ')
+		logfmtKey(buf, f.key)
+		buf.WriteByte('=')
+		logfmtQuote(buf, f.value)
+	}
+}
+
+func (logfmtEncoder) structured() bool { return true }
+
+// quoteMessage replaces the message in buf, which starts at offset start,
+// with its quoted form, less any final newline.
+func quoteMessage(buf *buffer, start int) {
+	msg := logging.getBuffer()
+	msg.Write(buf.Bytes()[start:])
+	buf.Truncate(start)
+	logfmtQuote(buf, strings.TrimSuffix(msg.String(), "\n"))
+	logging.putBuffer(msg)
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) header(buf *buffer) {
+	r := &buf.rec
+	buf.WriteString(`{"ts":"`)
+	buf.Write(r.time.AppendFormat(buf.tmp[:0], timestampFormat))
+	buf.WriteString(`","level":"`)
+	buf.WriteString(logfmtLevel[r.s])
+	buf.WriteString(`","caller":"`)
+	logfmtEscape(buf, PathBase.format(r.file))
+	buf.tmp[0] = ':'
+	n := buf.someDigits(1, r.line)
+	buf.Write(buf.tmp[:n+1])
+	buf.WriteByte('"')
+	if r.prefix != "" {
+		buf.WriteString(`,"prefix":`)
+		logfmtQuote(buf, r.prefix)
+	}
+	buf.WriteString(`,"msg":`)
+}
+
+// finish quotes the message, adds the data and closes the object.
+func (jsonEncoder) finish(buf *buffer, start int) {
+	quoteMessage(buf, start)
+	for _, f := range dataFields(buf.rec.data) {
+		buf.WriteByte(',')
+		logfmtQuote(buf, f.key)
+		buf.WriteByte(':')
+		logfmtQuote(buf, f.value)
+	}
+	buf.WriteByte('}')
+}
+
+func (jsonEncoder) structured() bool { return true }
+
+// logfmtKey writes key, replacing the characters not allowed in a logfmt
+// key with '_'.
+func logfmtKey(buf *buffer, key string) {
+	if key == "" {
+		buf.WriteByte('_')
+		return
+	}
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		if c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+			c = '_'
+		}
+		buf.WriteByte(c)
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+// logfmtQuote writes s as a double-quoted string, escaping quotes,
+// backslashes, control characters and invalid UTF-8. The result is also
+// a valid JSON string.
+func logfmtQuote(buf *buffer, s string) {
+	buf.WriteByte('"')
+	logfmtEscape(buf, s)
+	buf.WriteByte('"')
+}
+
+// logfmtEscape writes s escaped as for logfmtQuote, without the quotes.
+func logfmtEscape(buf *buffer, s string) {
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			if r == utf8.RuneError && size == 1 {
+				buf.WriteString("\ufffd")
+			} else {
+				buf.WriteString(s[i : i+size])
+			}
+			i += size
+			continue
+		}
+		switch c {
+		case '"', '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if c < ' ' || c == 0x7f {
+				buf.WriteString(`\u00`)
+				buf.WriteByte(hexDigits[c>>4])
+				buf.WriteByte(hexDigits[c&0xf])
+			} else {
+				buf.WriteByte(c)
+			}
+		}
+		i++
+	}
+}
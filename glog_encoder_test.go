@@ -0,0 +1,87 @@
+package glog
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogfmt(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetEncoder(SetEncoder(LogfmtEncoder))
+	defer func(previous func() time.Time) { timeNow = previous }(timeNow)
+	timeNow = func() time.Time {
+		return time.Date(2006, 1, 2, 15, 4, 5, .678901e9, time.UTC)
+	}
+
+	l := WithData(map[string]string{"user id": "alice"}).WithPrefix("[req 1]")
+	l.Infof("said %q\nthen left", "hi")
+	var line int
+	n, err := fmt.Sscanf(contents(),
+		`ts=2006-01-02T15:04:05.678901Z level=info caller=glog_encoder_test.go:%d prefix="[req 1]" msg="said \"hi\"\nthen left" user_id="alice"`+"\n",
+		&line)
+	if n != 1 || err != nil {
+		t.Errorf("log format error: %d elements, error %s:\n%s", n, err, contents())
+	}
+
+	fakeStdout.Reset()
+	Errorln("failed:", errors.New("bad\tthing"))
+	if !strings.HasSuffix(contents(), ` level=error caller=glog_encoder_test.go:`+fmt.Sprint(line+10)+
+		` msg="failed: bad\tthing" error="bad\tthing" root_cause="bad\tthing"`+"\n") {
+		t.Errorf("unexpected line: %q", contents())
+	}
+}
+
+func TestJSON(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetEncoder(SetEncoder(JSONEncoder))
+	defer func(previous func() time.Time) { timeNow = previous }(timeNow)
+	timeNow = func() time.Time {
+		return time.Date(2006, 1, 2, 15, 4, 5, .678901e9, time.UTC)
+	}
+
+	l := WithData(map[string]string{"user id": "alice"}).WithPrefix("[req 1]")
+	l.Infof("said %q\nthen left", "hi")
+	var line int
+	n, err := fmt.Sscanf(contents(),
+		`{"ts":"2006-01-02T15:04:05.678901Z","level":"info","caller":"glog_encoder_test.go:%d","prefix":"[req 1]","msg":"said \"hi\"\nthen left","user id":"alice"}`+"\n",
+		&line)
+	if n != 1 || err != nil {
+		t.Errorf("log format error: %d elements, error %s:\n%s", n, err, contents())
+	}
+}
+
+func TestLogfmtQuote(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", `""`},
+		{"plain", `"plain"`},
+		{`a "b" \c`, `"a \"b\" \\c"`},
+		{"\x00\x1b\r\x7f", `"\u0000\u001b\r\u007f"`},
+		{"héllo \xff", "\"héllo \ufffd\""},
+	}
+	for _, tt := range tests {
+		buf := &buffer{}
+		logfmtQuote(buf, tt.in)
+		if got := buf.String(); got != tt.want {
+			t.Errorf("logfmtQuote(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLogFormatFlag(t *testing.T) {
+	defer SetEncoder(SetEncoder(nil))
+	var f encoderFlag
+	if err := f.Set("logfmt"); err != nil || f.String() != "logfmt" {
+		t.Errorf("Set(logfmt) gave error %v, format %s", err, f.String())
+	}
+	if err := f.Set("xml"); err == nil {
+		t.Error("Set(xml) did not fail")
+	}
+	if f.String() != "logfmt" {
+		t.Errorf("format changed to %s after an error", f.String())
+	}
+}
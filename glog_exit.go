@@ -0,0 +1,100 @@
+package glog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Exit codes passed to the exit function.
+const (
+	// ExitCodeFatal is used by Fatal and its relatives.
+	ExitCodeFatal = 255 // C++ uses -1, which is silly because it's anded with 255 anyway.
+	// ExitCodeExit is used by Exit and its relatives.
+	ExitCodeExit = 1
+	// ExitCodeWriteError is used when the log output cannot be written.
+	ExitCodeWriteError = 2
+)
+
+var (
+	exitMu    sync.Mutex
+	exitFunc  = os.Exit
+	exitHooks []func()
+	// exiting is non-zero while the exit hooks are running, so that a hook
+	// which itself logs to FATAL does not run them all over again.
+	exiting uint32
+)
+
+// RegisterExitHook adds a function to be called before the program exits
+// because of Fatal, Exit or one of their relatives. Hooks are called in the
+// order they were registered, after the log line has been written and
+// before the backends and logs are flushed.
+func RegisterExitHook(hook func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHooks = append(exitHooks, hook)
+}
+
+// SetExitFunc overrides the function used to terminate the program after
+// Fatal, Exit or one of their relatives, and returns the previous one.
+// The function is passed the exit code the program would otherwise exit
+// with; see ExitCodeFatal and friends. Passing nil restores os.Exit.
+//
+// The function may exit with a different code, panic (see PanicOnExit) or
+// simply return, in which case the logging call returns normally. The last
+// is mostly useful in tests.
+func SetExitFunc(f func(code int)) func(code int) {
+	if f == nil {
+		f = os.Exit
+	}
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	previous := exitFunc
+	exitFunc = f
+	return previous
+}
+
+// ExitError is the value passed to panic by PanicOnExit.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("glog: exit with code %d", e.Code)
+}
+
+// PanicOnExit is an exit function for use with SetExitFunc that panics with
+// an *ExitError instead of terminating the program.
+func PanicOnExit(code int) {
+	panic(&ExitError{Code: code})
+}
+
+// runExit calls the exit hooks, flushes the backends and the logs and calls
+// the exit function. l.mu is not held.
+func runExit(code int) {
+	if atomic.CompareAndSwapUint32(&exiting, 0, 1) {
+		exitMu.Lock()
+		hooks := exitHooks
+		exitMu.Unlock()
+		func() {
+			defer atomic.StoreUint32(&exiting, 0)
+			for _, hook := range hooks {
+				hook()
+			}
+		}()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if err := flushBackendsOnExit(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "glog: flushing backends:", err)
+	}
+	cancel()
+	timeoutFlush(10 * time.Second)
+
+	exitMu.Lock()
+	f := exitFunc
+	exitMu.Unlock()
+	f(code)
+}
@@ -0,0 +1,93 @@
+package glog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFatalCallsExitFunc(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	var calls []string
+	var code int
+	defer SetExitFunc(SetExitFunc(func(c int) {
+		calls = append(calls, "exit")
+		code = c
+	}))
+	defer func(hooks []func()) { exitHooks = hooks }(exitHooks)
+	RegisterExitHook(func() { calls = append(calls, "hook 1") })
+	RegisterExitHook(func() { calls = append(calls, "hook 2") })
+
+	Fatal("fatal message")
+
+	if got, want := strings.Join(calls, ","), "hook 1,hook 2,exit"; got != want {
+		t.Errorf("got calls %q, want %q", got, want)
+	}
+	if code != ExitCodeFatal {
+		t.Errorf("got exit code %d, want %d", code, ExitCodeFatal)
+	}
+	if !contains("fatal message", t) {
+		t.Errorf("Fatal message was not written: %q", contents())
+	}
+	if !contains("goroutine ", t) {
+		t.Errorf("Fatal did not write a stack trace: %q", contents())
+	}
+}
+
+func TestExitCallsExitFunc(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	var code int
+	defer SetExitFunc(SetExitFunc(func(c int) { code = c }))
+
+	Exit("exit message")
+
+	if code != ExitCodeExit {
+		t.Errorf("got exit code %d, want %d", code, ExitCodeExit)
+	}
+	if contains("goroutine ", t) {
+		t.Errorf("Exit wrote a stack trace: %q", contents())
+	}
+
+	// A returning exit function must not leave Fatal without stacks.
+	Fatal("fatal message")
+	if code != ExitCodeFatal {
+		t.Errorf("got exit code %d, want %d", code, ExitCodeFatal)
+	}
+}
+
+func TestPanicOnExit(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetExitFunc(SetExitFunc(PanicOnExit))
+
+	defer func() {
+		e, ok := recover().(*ExitError)
+		if !ok || e.Code != ExitCodeFatal {
+			t.Errorf("got panic %v, want exit code %d", e, ExitCodeFatal)
+		}
+	}()
+	Fatalf("fatal %s", "message")
+	t.Error("Fatalf returned")
+}
+
+func TestExitHookMayLogFatal(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	codes := 0
+	defer SetExitFunc(SetExitFunc(func(int) { codes++ }))
+	defer func(hooks []func()) { exitHooks = hooks }(exitHooks)
+	hooks := 0
+	RegisterExitHook(func() {
+		hooks++
+		Fatal("fatal from hook")
+	})
+
+	Fatal("fatal message")
+
+	if hooks != 1 {
+		t.Errorf("hook ran %d times, want 1", hooks)
+	}
+	if codes != 2 {
+		t.Errorf("exit function ran %d times, want 2", codes)
+	}
+}
@@ -0,0 +1,60 @@
+package glog
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+var publishExpvarOnce sync.Once
+
+// PublishExpvar publishes the logging configuration and counters as the
+// expvar variable "glog", so they appear on /debug/vars alongside other
+// runtime metrics. It is safe to call more than once.
+func PublishExpvar() {
+	publishExpvarOnce.Do(func() {
+		expvar.Publish("glog", expvar.Func(expvarValue))
+	})
+}
+
+// expvarStats is the expvar form of OutputStats.
+type expvarStats struct {
+	Lines int64
+	Bytes int64
+}
+
+// expvarValue returns the value of the "glog" expvar variable.
+func expvarValue() interface{} {
+	stats := make(map[string]expvarStats, numSeverity)
+	for s, st := range severityStats {
+		stats[severityName[s]] = expvarStats{st.Lines(), st.Bytes()}
+	}
+	stats["WriteErrors"] = expvarStats{Stats.WriteErrors.Lines(), Stats.WriteErrors.Bytes()}
+	stats["AsyncDropped"] = expvarStats{Stats.AsyncDropped.Lines(), Stats.AsyncDropped.Bytes()}
+	stats["Truncated"] = expvarStats{Stats.Truncated.Lines(), Stats.Truncated.Bytes()}
+
+	logging.mu.Lock()
+	var trace string
+	if logging.traceLocation.isSet() {
+		trace = fmt.Sprintf("%s:%d", logging.traceLocation.file, logging.traceLocation.line)
+	}
+	logging.mu.Unlock()
+
+	backendChanMu.RLock()
+	backends := len(backendChans)
+	backendChanMu.RUnlock()
+
+	return map[string]interface{}{
+		"v":                logging.verbosity.get(),
+		"vmodule":          logging.vmodule.String(),
+		"log_backtrace_at": trace,
+		"backends":         backends,
+		"backend_events": map[string]int64{
+			"delivered": atomic.LoadInt64(&backendStats.delivered),
+			"dropped":   atomic.LoadInt64(&backendStats.dropped),
+			"failed":    atomic.LoadInt64(&backendStats.failed),
+		},
+		"stats": stats,
+	}
+}
@@ -0,0 +1,39 @@
+package glog
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestPublishExpvar(t *testing.T) {
+	PublishExpvar()
+	PublishExpvar()
+
+	logging.vmodule.Set("glog_expvar_test=2")
+	defer logging.vmodule.Set("")
+
+	v := expvar.Get("glog")
+	if v == nil {
+		t.Fatal("glog was not published")
+	}
+	var got struct {
+		V              int
+		VModule        string `json:"vmodule"`
+		LogBacktraceAt string `json:"log_backtrace_at"`
+		Backends       int
+		Stats          map[string]struct{ Lines, Bytes int64 }
+	}
+	if err := json.Unmarshal([]byte(v.String()), &got); err != nil {
+		t.Fatalf("%v: %s", err, v.String())
+	}
+	if got.VModule != "glog_expvar_test=2" {
+		t.Errorf("got vmodule %q, want glog_expvar_test=2", got.VModule)
+	}
+	if got.LogBacktraceAt != "" {
+		t.Errorf("got log_backtrace_at %q, want none", got.LogBacktraceAt)
+	}
+	if _, ok := got.Stats["FATAL"]; !ok {
+		t.Errorf("no FATAL stats: %s", v.String())
+	}
+}
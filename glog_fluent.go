@@ -0,0 +1,321 @@
+package glog
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// FluentOptions configures a FluentBackend.
+type FluentOptions struct {
+	// Network is "tcp", the default, or "unix".
+	Network string
+	// Addr is the address of the forward input, or the path of its
+	// socket. It defaults to "127.0.0.1:24224".
+	Addr string
+	// Tag is the tag of events logged without a Logger prefix, and the
+	// start of the tag of the others. It defaults to "glog".
+	Tag string
+	// RequireAck makes the backend wait for the server to acknowledge
+	// each message, and send it again, on a new connection, if it does
+	// not. Events are then delivered at least once.
+	RequireAck bool
+	// AckTimeout is how long to wait for an acknowledgement. It defaults
+	// to 30 seconds.
+	AckTimeout time.Duration
+	// Timeout is as for SyslogOptions.
+	Timeout time.Duration
+	// BatchSize and BatchInterval are as for WebhookOptions, but
+	// BatchInterval defaults to 1 second.
+	BatchSize     int
+	BatchInterval time.Duration
+	// MaxRetries, MinBackoff and MaxBackoff bound the retries of a message
+	// that could not be sent, as for WebhookOptions.
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// BufferLimit is the number of messages kept while the server cannot
+	// be reached, to be sent once it can, beyond which the oldest are
+	// discarded. Each message holds the events of a batch with the same
+	// tag. It defaults to 100.
+	BufferLimit int
+}
+
+// FluentBackend sends events to Fluentd or Fluent Bit using the forward
+// protocol, in Forward mode. Events are sent in batches, one message for
+// each tag. The tag of an event is the Tag option followed by the prefix
+// of the Logger that logged it, if any, as in "glog.billing" for the
+// prefix "[billing]": characters other than letters, digits, '-' and '_'
+// become '_', and any at either end are dropped. The record of an event
+// holds its message, less the classic header, its severity, file, line,
+// function, fingerprint and prefix, and each field from Event.Data, as for
+// dataFields.
+type FluentBackend struct {
+	opts  FluentOptions
+	retry retryPolicy
+	// The remaining fields are used only by the batcher's goroutine, and
+	// by Close once the batcher has stopped.
+	batcher *batcher
+	conn    net.Conn
+	decoder *msgpackDecoder
+	pending []fluentMessage
+}
+
+// fluentMessage is an encoded message waiting to be sent.
+type fluentMessage struct {
+	chunk string // the chunk ID to be acknowledged, if any
+	data  []byte
+}
+
+// NewFluentBackend creates a FluentBackend. To send it every event:
+//
+//	glog.AddBackend(glog.NewFluentBackend(glog.FluentOptions{RequireAck: true}))
+func NewFluentBackend(opts FluentOptions) *FluentBackend {
+	if opts.Network == "" {
+		opts.Network = "tcp"
+	}
+	if opts.Addr == "" {
+		opts.Addr = "127.0.0.1:24224"
+	}
+	if opts.Tag == "" {
+		opts.Tag = "glog"
+	}
+	if opts.AckTimeout <= 0 {
+		opts.AckTimeout = 30 * time.Second
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.BatchInterval <= 0 {
+		opts.BatchInterval = time.Second
+	}
+	if opts.BufferLimit <= 0 {
+		opts.BufferLimit = 100
+	}
+	b := &FluentBackend{
+		opts:  opts,
+		retry: newRetryPolicy(opts.MaxRetries, opts.MinBackoff, opts.MaxBackoff),
+	}
+	b.batcher = newBatcher(opts.BatchSize, opts.BatchInterval, b.sendBatch)
+	return b
+}
+
+// Handle adds e to the current batch. Errors sending batches are reported
+// to the backend error handler as they happen, so it always returns nil.
+func (b *FluentBackend) Handle(e Event) error {
+	b.batcher.add(e)
+	return nil
+}
+
+// Flush sends the current batch without waiting for it to fill, along
+// with any messages kept while the server could not be reached, even if
+// the batch is empty. It returns the last error from sending them.
+func (b *FluentBackend) Flush(ctx context.Context) error {
+	return b.batcher.flush(ctx)
+}
+
+// Close sends the current batch, stops the backend and closes the
+// connection. Messages that could not be sent are discarded.
+func (b *FluentBackend) Close() error {
+	b.batcher.close()
+	return b.closeConn()
+}
+
+// fluentTag returns the tag for events logged with prefix.
+func (b *FluentBackend) fluentTag(prefix string) string {
+	t := []byte(prefix)
+	for i, c := range t {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-') {
+			t[i] = '_'
+		}
+	}
+	if s := strings.Trim(string(t), "_"); s != "" {
+		return b.opts.Tag + "." + s
+	}
+	return b.opts.Tag
+}
+
+// sendBatch encodes a batch of events into a message for each tag, and
+// sends them after any kept from earlier batches. It returns the last
+// error, which has been reported.
+func (b *FluentBackend) sendBatch(ctx context.Context, events []Event) error {
+	var lastErr error
+	var tags []string
+	byTag := make(map[string][]Event)
+	for _, e := range events {
+		tag := b.fluentTag(e.Prefix)
+		if _, ok := byTag[tag]; !ok {
+			tags = append(tags, tag)
+		}
+		byTag[tag] = append(byTag[tag], e)
+	}
+	for _, tag := range tags {
+		m, err := b.encode(tag, byTag[tag])
+		if err != nil {
+			reportBackendError(b, err)
+			lastErr = err
+			continue
+		}
+		b.pending = append(b.pending, m)
+	}
+	if n := len(b.pending) - b.opts.BufferLimit; n > 0 {
+		lastErr = fmt.Errorf("fluent: discarded %d messages over the buffer limit", n)
+		reportBackendError(b, lastErr)
+		b.pending = append(b.pending[:0:0], b.pending[n:]...)
+	}
+
+	for len(b.pending) > 0 {
+		if err := b.sendWithRetries(ctx, b.pending[0]); err != nil {
+			reportBackendError(b, err)
+			return err
+		}
+		b.pending[0] = fluentMessage{}
+		b.pending = b.pending[1:]
+	}
+	return lastErr
+}
+
+// encode encodes events as a Forward mode message:
+//
+//	[tag, [[time, record], ...], {"size": n, "chunk": id}]
+func (b *FluentBackend) encode(tag string, events []Event) (fluentMessage, error) {
+	var m fluentMessage
+	if b.opts.RequireAck {
+		var id [16]byte
+		if _, err := rand.Read(id[:]); err != nil {
+			return m, err
+		}
+		m.chunk = base64.StdEncoding.EncodeToString(id[:])
+	}
+
+	var enc msgpackEncoder
+	enc.writeArrayHeader(3)
+	enc.writeString(tag)
+	enc.writeArrayHeader(len(events))
+	for _, e := range events {
+		enc.writeArrayHeader(2)
+		t := e.Time
+		if t.IsZero() {
+			t = timeNow()
+		}
+		enc.writeEventTime(t)
+		b.encodeRecord(&enc, e)
+	}
+	if m.chunk != "" {
+		enc.writeMapHeader(2)
+		enc.writeString("size")
+		enc.writeInt(int64(len(events)))
+		enc.writeString("chunk")
+		enc.writeString(m.chunk)
+	} else {
+		enc.writeMapHeader(1)
+		enc.writeString("size")
+		enc.writeInt(int64(len(events)))
+	}
+	m.data = enc.buf
+	return m, nil
+}
+
+// encodeRecord writes the record of e as a map.
+func (b *FluentBackend) encodeRecord(enc *msgpackEncoder, e Event) {
+	fields := []dataField{
+		{"message", e.text()},
+		{"severity", e.Severity},
+	}
+	var line int64 = -1
+	if e.PC != 0 {
+		site := siteForPC(e.PC)
+		fields = append(fields, dataField{"file", site.file}, dataField{"function", site.function})
+		line = int64(site.line)
+	}
+	if e.Fingerprint != "" {
+		fields = append(fields, dataField{"fingerprint", e.Fingerprint})
+	}
+	if e.Prefix != "" {
+		fields = append(fields, dataField{"prefix", e.Prefix})
+	}
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		seen[f.key] = true
+	}
+	for _, f := range dataFields(e.Data) {
+		if seen[f.key] || f.key == "line" && line >= 0 {
+			f.key = "data_" + f.key
+		}
+		fields = append(fields, f)
+	}
+
+	n := len(fields)
+	if line >= 0 {
+		n++
+	}
+	enc.writeMapHeader(n)
+	for _, f := range fields {
+		enc.writeString(f.key)
+		enc.writeString(f.value)
+	}
+	if line >= 0 {
+		enc.writeString("line")
+		enc.writeInt(line)
+	}
+}
+
+// sendWithRetries sends m, on a new connection after each failure, with
+// exponential backoff until the retries run out or ctx is done.
+func (b *FluentBackend) sendWithRetries(ctx context.Context, m fluentMessage) error {
+	_, err := b.retry.do(ctx, func() (bool, error) {
+		err := b.send(m)
+		if err != nil {
+			b.closeConn()
+		}
+		return true, err
+	})
+	return err
+}
+
+// send sends m, dialing first if there is no connection, and waits for
+// its acknowledgement if one was asked for.
+func (b *FluentBackend) send(m fluentMessage) error {
+	if b.conn == nil {
+		conn, err := net.DialTimeout(b.opts.Network, b.opts.Addr, b.opts.Timeout)
+		if err != nil {
+			return err
+		}
+		b.conn = conn
+		b.decoder = newMsgpackDecoder(conn)
+	}
+	b.conn.SetWriteDeadline(time.Now().Add(b.opts.Timeout))
+	if _, err := b.conn.Write(m.data); err != nil {
+		return err
+	}
+	if m.chunk == "" {
+		return nil
+	}
+
+	b.conn.SetReadDeadline(time.Now().Add(b.opts.AckTimeout))
+	resp, err := b.decoder.decode()
+	if err != nil {
+		return fmt.Errorf("fluent: waiting for ack: %v", err)
+	}
+	if r, ok := resp.(map[string]interface{}); !ok || r["ack"] != m.chunk {
+		return errors.New("fluent: unexpected response to message")
+	}
+	return nil
+}
+
+func (b *FluentBackend) closeConn() error {
+	if b.conn == nil {
+		return nil
+	}
+	err := b.conn.Close()
+	b.conn, b.decoder = nil, nil
+	return err
+}
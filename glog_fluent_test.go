@@ -0,0 +1,225 @@
+package glog
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestFluentTag(t *testing.T) {
+	b := NewFluentBackend(FluentOptions{Tag: "app"})
+	defer b.Close()
+	for prefix, want := range map[string]string{
+		"":           "app",
+		"[billing]":  "app.billing",
+		"[a b/c-d] ": "app.a_b_c-d",
+		"[]":         "app",
+	} {
+		if got := b.fluentTag(prefix); got != want {
+			t.Errorf("fluentTag(%q) = %q, want %q", prefix, got, want)
+		}
+	}
+}
+
+// fluentServer is a stand-in for a forward input that acknowledges each
+// message it receives, except that it drops the first connection after
+// reading its first message without acknowledging it.
+type fluentServer struct {
+	l        net.Listener
+	messages chan []interface{}
+}
+
+func newFluentServer(t *testing.T) *fluentServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fluentServer{l: l, messages: make(chan []interface{}, 10)}
+	go s.serve()
+	return s
+}
+
+func (s *fluentServer) serve() {
+	first := true
+	for {
+		conn, err := s.l.Accept()
+		if err != nil {
+			return
+		}
+		d := newMsgpackDecoder(conn)
+		for {
+			v, err := d.decode()
+			if err != nil {
+				break
+			}
+			m, _ := v.([]interface{})
+			s.messages <- m
+			if first {
+				first = false
+				break
+			}
+			if len(m) == 3 {
+				opts, _ := m[2].(map[string]interface{})
+				var enc msgpackEncoder
+				enc.writeMapHeader(1)
+				enc.writeString("ack")
+				enc.writeString(opts["chunk"].(string))
+				conn.Write(enc.buf)
+			}
+		}
+		conn.Close()
+	}
+}
+
+func (s *fluentServer) next(t *testing.T) []interface{} {
+	select {
+	case m := <-s.messages:
+		return m
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+		return nil
+	}
+}
+
+func TestFluentAck(t *testing.T) {
+	s := newFluentServer(t)
+	defer s.l.Close()
+	b := NewFluentBackend(FluentOptions{
+		Addr:       s.l.Addr().String(),
+		RequireAck: true,
+		AckTimeout: time.Second,
+		MinBackoff: time.Millisecond,
+	})
+	defer b.Close()
+
+	e := Event{
+		Severity: "WARNING",
+		Message:  []byte("W1018 12:00:00.000000 file.go:1] [billing]fluent test\n"),
+		Body:     "[billing]fluent test",
+		Data:     []interface{}{map[string]string{"user": "alice"}},
+		Time:     time.Unix(1136214245, 678901000),
+		Prefix:   "[billing]",
+	}
+	if err := b.Handle(e); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first message was not acknowledged, so it is sent again, with
+	// the same chunk ID, on a new connection.
+	first, second := s.next(t), s.next(t)
+	if len(first) != 3 || len(second) != 3 {
+		t.Fatalf("bad messages %v and %v", first, second)
+	}
+	chunk := first[2].(map[string]interface{})["chunk"]
+	if chunk == nil || second[2].(map[string]interface{})["chunk"] != chunk {
+		t.Errorf("chunk IDs %v and %v differ", first[2], second[2])
+	}
+	if second[0] != "glog.billing" {
+		t.Errorf("tag is %v, want glog.billing", second[0])
+	}
+	entries := second[1].([]interface{})
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	entry := entries[0].([]interface{})
+	if ts, ok := entry[0].(msgpackExt); !ok || ts.Type != 0 || len(ts.Data) != 8 {
+		t.Errorf("time is %v, want an EventTime", entry[0])
+	}
+	record := entry[1].(map[string]interface{})
+	for k, v := range map[string]interface{}{
+		"message":  "[billing]fluent test",
+		"severity": "WARNING",
+		"prefix":   "[billing]",
+		"user":     "alice",
+	} {
+		if record[k] != v {
+			t.Errorf("%s is %v, want %v", k, record[k], v)
+		}
+	}
+}
+
+func TestFluentBufferLimit(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	var errs []error
+	defer SetBackendErrorHandler(SetBackendErrorHandler(func(_ Backend, err error) { errs = append(errs, err) }))
+	b := NewFluentBackend(FluentOptions{Addr: addr, MaxRetries: -1, BufferLimit: 2})
+	defer b.Close()
+	for i := 0; i < 3; i++ {
+		b.Handle(Event{Severity: "INFO", Message: []byte("lost\n"), Body: "lost"})
+		b.Flush(context.Background())
+	}
+	if len(b.pending) != 2 {
+		t.Errorf("kept %d messages, want 2", len(b.pending))
+	}
+	if len(errs) == 0 {
+		t.Error("no errors reported")
+	}
+}
+
+func TestFluentFlushPending(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	defer SetBackendErrorHandler(SetBackendErrorHandler(func(Backend, error) {}))
+	b := NewFluentBackend(FluentOptions{Addr: addr, MaxRetries: -1})
+	defer b.Close()
+	b.Handle(Event{Severity: "INFO", Message: []byte("kept\n"), Body: "kept"})
+	if err := b.Flush(context.Background()); err == nil {
+		t.Error("Flush returned nil with the server down")
+	}
+
+	// Once the server is up, an empty flush sends the kept message.
+	l, err = net.Listen("tcp", addr)
+	if err != nil {
+		t.Skip(err)
+	}
+	s := &fluentServer{l: l, messages: make(chan []interface{}, 10)}
+	go s.serve()
+	defer l.Close()
+	if err := b.Flush(context.Background()); err != nil {
+		t.Errorf("Flush returned %v", err)
+	}
+	if m := s.next(t); len(m) != 3 || m[0] != "glog" {
+		t.Errorf("bad message %v", m)
+	}
+	if len(b.pending) != 0 {
+		t.Errorf("kept %d messages, want 0", len(b.pending))
+	}
+}
+
+func TestFluentStopRetrying(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	defer SetBackendErrorHandler(SetBackendErrorHandler(func(Backend, error) {}))
+	b := NewFluentBackend(FluentOptions{Addr: addr, MinBackoff: time.Hour})
+	b.Handle(Event{Severity: "INFO", Message: []byte("lost\n"), Body: "lost"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := b.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Flush returned %v, want %v", err, context.DeadlineExceeded)
+	}
+	b.Close()
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Flush and Close took %v", d)
+	}
+}
@@ -0,0 +1,241 @@
+package glog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GELFCompression selects how GELF messages sent over UDP are compressed.
+type GELFCompression int
+
+const (
+	// GELFUncompressed sends messages as they are. This is the default.
+	GELFUncompressed GELFCompression = iota
+	// GELFGzip compresses messages with gzip.
+	GELFGzip
+	// GELFZlib compresses messages with zlib.
+	GELFZlib
+)
+
+// GELFOptions configures a GELFBackend.
+type GELFOptions struct {
+	// Network is "udp", the default, or "tcp". Over UDP, messages that do
+	// not fit in a datagram are chunked; over TCP, each message is ended
+	// by a null byte.
+	Network string
+	// Addr is the address of the GELF input, such as "graylog:12201".
+	Addr string
+	// Host is the host field of each message. It defaults to the name
+	// reported by os.Hostname.
+	Host string
+	// Compression applies to UDP only, as Graylog does not accept
+	// compressed messages over TCP.
+	Compression GELFCompression
+	// ChunkSize is the largest datagram sent over UDP. It defaults to
+	// 1420 bytes, which suits most networks; up to 8192 may be used
+	// within a LAN.
+	ChunkSize int
+	// Timeout is as for SyslogOptions.
+	Timeout time.Duration
+}
+
+// GELFBackend sends events to Graylog, or anything else that accepts the
+// Graylog Extended Log Format 1.1. The short_message is the first line of
+// the message, less the classic header, and the full_message is the whole
+// of it if it has more lines. The level is the syslog severity, _file and
+// _line give the logging call, and each field from Event.Data, as for
+// dataFields, becomes an additional field. The connection is made on
+// first use, and remade if a write fails.
+type GELFBackend struct {
+	opts   GELFOptions
+	sender *netSender
+}
+
+// NewGELFBackend creates a GELFBackend. To send it every event:
+//
+//	glog.AddBackend(glog.NewGELFBackend(glog.GELFOptions{Addr: "graylog:12201"}))
+func NewGELFBackend(opts GELFOptions) *GELFBackend {
+	if opts.Network == "" {
+		opts.Network = "udp"
+	}
+	if opts.Host == "" {
+		opts.Host, _ = os.Hostname()
+	}
+	if opts.ChunkSize <= gelfChunkHeaderSize {
+		opts.ChunkSize = 1420
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	return &GELFBackend{
+		opts:   opts,
+		sender: &netSender{network: opts.Network, addr: opts.Addr, timeout: opts.Timeout},
+	}
+}
+
+// Handle sends e, reconnecting once if need be.
+func (b *GELFBackend) Handle(e Event) error {
+	msg, err := b.format(e)
+	if err != nil {
+		return err
+	}
+	var packets [][]byte
+	if b.opts.Network == "tcp" {
+		packets = [][]byte{append(msg, 0)}
+	} else {
+		if msg, err = b.compress(msg); err != nil {
+			return err
+		}
+		if packets, err = b.chunk(msg); err != nil {
+			return err
+		}
+	}
+
+	return b.sender.send(packets...)
+}
+
+// Flush does nothing, as events are sent as they are handled.
+func (b *GELFBackend) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close closes the connection, if any.
+func (b *GELFBackend) Close() error {
+	return b.sender.close()
+}
+
+// format returns e as a GELF 1.1 message.
+func (b *GELFBackend) format(e Event) ([]byte, error) {
+	level, ok := syslogSeverity[e.Severity]
+	if !ok {
+		level = 5 // notice
+	}
+	t := e.Time
+	if t.IsZero() {
+		t = timeNow()
+	}
+	body := e.text()
+	short := body
+	if i := strings.IndexByte(body, '\n'); i >= 0 {
+		short = body[:i]
+	}
+	if short == "" {
+		// GELF requires a short_message.
+		short = "-"
+	}
+
+	m := map[string]interface{}{
+		"version":       "1.1",
+		"host":          b.opts.Host,
+		"short_message": short,
+		"timestamp":     json.Number(strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 6, 64)),
+		"level":         level,
+	}
+	if short != body {
+		m["full_message"] = body
+	}
+	if e.PC != 0 {
+		site := siteForPC(e.PC)
+		m["_file"] = site.file
+		m["_line"] = site.line
+		m["_function"] = site.function
+	}
+	if e.Fingerprint != "" {
+		m["_fingerprint"] = e.Fingerprint
+	}
+	for _, f := range dataFields(e.Data) {
+		key := gelfFieldName(f.key)
+		if _, ok := m[key]; ok {
+			// Keep the standard fields; data fields are unique already.
+			key += "_data"
+		}
+		m[key] = f.value
+	}
+	return json.Marshal(m)
+}
+
+// gelfFieldName returns the name of the additional field for key, which
+// starts with '_' and has only the characters GELF allows. The field
+// "_id" is reserved, so "id" becomes "_id_".
+func gelfFieldName(key string) string {
+	b := []byte("_" + key)
+	for i, c := range b {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-') {
+			b[i] = '_'
+		}
+	}
+	if string(b) == "_id" {
+		return "_id_"
+	}
+	return string(b)
+}
+
+// compress compresses msg as set by the Compression option.
+func (b *GELFBackend) compress(msg []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w interface {
+		Write([]byte) (int, error)
+		Close() error
+	}
+	switch b.opts.Compression {
+	case GELFGzip:
+		w = gzip.NewWriter(&buf)
+	case GELFZlib:
+		w = zlib.NewWriter(&buf)
+	default:
+		return msg, nil
+	}
+	if _, err := w.Write(msg); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+const (
+	// gelfChunkHeaderSize is the size of the header of a chunk: two magic
+	// bytes, an 8 byte message ID, the sequence number and the count.
+	gelfChunkHeaderSize = 12
+	// gelfMaxChunks is the most chunks a message may be split into.
+	gelfMaxChunks = 128
+)
+
+// chunk splits msg into datagrams of no more than the chunk size.
+func (b *GELFBackend) chunk(msg []byte) ([][]byte, error) {
+	if len(msg) <= b.opts.ChunkSize {
+		return [][]byte{msg}, nil
+	}
+	size := b.opts.ChunkSize - gelfChunkHeaderSize
+	n := (len(msg) + size - 1) / size
+	if n > gelfMaxChunks {
+		return nil, fmt.Errorf("GELF message of %d bytes needs %d chunks, more than %d", len(msg), n, gelfMaxChunks)
+	}
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	chunks := make([][]byte, n)
+	for i := range chunks {
+		data := msg[i*size:]
+		if len(data) > size {
+			data = data[:size]
+		}
+		c := make([]byte, 0, gelfChunkHeaderSize+len(data))
+		c = append(c, 0x1e, 0x0f)
+		c = append(c, id[:]...)
+		c = append(c, byte(i), byte(n))
+		chunks[i] = append(c, data...)
+	}
+	return chunks, nil
+}
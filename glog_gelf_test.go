@@ -0,0 +1,190 @@
+package glog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+var gelfTestEvent = Event{
+	Severity:    "ERROR",
+	Message:     []byte("E1018 12:00:00.000000 file.go:1] gelf test\nsecond line\n"),
+	Body:        "gelf test\nsecond line",
+	Data:        []interface{}{ErrorArg{errors.New("bad thing")}, map[string]string{"user id": "alice", "id": "7"}},
+	Time:        time.Date(2006, 1, 2, 15, 4, 5, 678901000, time.UTC),
+	Fingerprint: "0123456789abcdef",
+}
+
+func TestGELFFormat(t *testing.T) {
+	b := NewGELFBackend(GELFOptions{Host: "host"})
+	msg, err := b.format(gelfTestEvent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal(msg, &m); err != nil {
+		t.Fatalf("%s is not JSON: %v", msg, err)
+	}
+	want := map[string]interface{}{
+		"version":       "1.1",
+		"host":          "host",
+		"short_message": "gelf test",
+		"full_message":  "gelf test\nsecond line",
+		"timestamp":     1136214245.678901,
+		"level":         3.0,
+		"_fingerprint":  "0123456789abcdef",
+		"_error":        "bad thing",
+		"_root_cause":   "bad thing",
+		"_user_id":      "alice",
+		"_id_":          "7",
+	}
+	if len(m) != len(want) {
+		t.Errorf("got fields %v, want %v", m, want)
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Errorf("%s is %v, want %v", k, m[k], v)
+		}
+	}
+}
+
+// readGELF decodes a GELF message, decompressing it as need be.
+func readGELF(t *testing.T, msg []byte) map[string]interface{} {
+	var r io.Reader = bytes.NewReader(msg)
+	var err error
+	switch {
+	case bytes.HasPrefix(msg, []byte{0x1f, 0x8b}):
+		r, err = gzip.NewReader(r)
+	case msg[0] == 0x78:
+		r, err = zlib.NewReader(r)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m map[string]interface{}
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		t.Fatalf("bad GELF message: %v", err)
+	}
+	return m
+}
+
+func TestGELFUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	buf := make([]byte, 65536)
+	read := func() []byte {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append([]byte(nil), buf[:n]...)
+	}
+
+	for _, c := range []GELFCompression{GELFUncompressed, GELFGzip, GELFZlib} {
+		b := NewGELFBackend(GELFOptions{Addr: conn.LocalAddr().String(), Compression: c})
+		if err := b.Handle(gelfTestEvent); err != nil {
+			t.Fatal(err)
+		}
+		b.Close()
+		if m := readGELF(t, read()); m["short_message"] != "gelf test" {
+			t.Errorf("compression %d: unexpected message %v", c, m)
+		}
+	}
+
+	// A message too big for a datagram is chunked.
+	b := NewGELFBackend(GELFOptions{Addr: conn.LocalAddr().String(), Compression: GELFZlib, ChunkSize: 100})
+	defer b.Close()
+	e := gelfTestEvent
+	e.Body = strings.Repeat("big ", 10) + "\n" + strings.Repeat("0123456789", 100)
+	e.Message = []byte("E1018 12:00:00.000000 file.go:1] " + e.Body)
+	if err := b.Handle(e); err != nil {
+		t.Fatal(err)
+	}
+	var (
+		msg   []byte
+		id    []byte
+		count int
+	)
+	for seq := 0; count == 0 || seq < count; seq++ {
+		c := read()
+		if len(c) > 100 || c[0] != 0x1e || c[1] != 0x0f || int(c[10]) != seq {
+			t.Fatalf("bad chunk %d: % x", seq, c[:12])
+		}
+		if id == nil {
+			id, count = c[2:10], int(c[11])
+		} else if !bytes.Equal(id, c[2:10]) || int(c[11]) != count {
+			t.Fatalf("chunk %d has a different ID or count", seq)
+		}
+		msg = append(msg, c[12:]...)
+	}
+	if count < 2 {
+		t.Errorf("sent in %d chunks", count)
+	}
+	if m := readGELF(t, msg); m["short_message"] != strings.Repeat("big ", 10) {
+		t.Errorf("unexpected chunked message %v", m)
+	}
+}
+
+func TestGELFTooManyChunks(t *testing.T) {
+	b := NewGELFBackend(GELFOptions{ChunkSize: 20})
+	if _, err := b.chunk(make([]byte, 8*gelfMaxChunks+1)); err == nil {
+		t.Error("no error for a message needing too many chunks")
+	}
+}
+
+func TestGELFTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	received := make(chan [][]byte, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			received <- nil
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var msgs [][]byte
+		for len(msgs) < 2 {
+			msg, err := r.ReadBytes(0)
+			if err != nil {
+				break
+			}
+			msgs = append(msgs, msg)
+		}
+		received <- msgs
+	}()
+
+	b := NewGELFBackend(GELFOptions{Network: "tcp", Addr: l.Addr().String(), Compression: GELFGzip})
+	defer b.Close()
+	for i := 0; i < 2; i++ {
+		if err := b.Handle(gelfTestEvent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	msgs := <-received
+	if len(msgs) != 2 {
+		t.Fatalf("got %d messages, want 2", len(msgs))
+	}
+	for _, msg := range msgs {
+		// Messages over TCP are never compressed.
+		if m := readGELF(t, bytes.TrimSuffix(msg, []byte{0})); m["short_message"] != "gelf test" {
+			t.Errorf("unexpected message %v", m)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package glog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// httpSender POSTs JSON bodies to an endpoint for the backends that use
+// HTTP, retrying failed requests with exponential backoff.
+type httpSender struct {
+	url    string
+	client *http.Client
+	header http.Header
+	retry  retryPolicy
+	// retryStatus reports whether a request that failed with the given
+	// status is worth retrying.
+	retryStatus func(code int) bool
+}
+
+// retryServerErrors retries requests that failed with a server error or
+// 429 Too Many Requests.
+func retryServerErrors(code int) bool {
+	return code >= 500 || code == http.StatusTooManyRequests
+}
+
+// post POSTs body, retrying with exponential backoff on failures worth
+// retrying until the retries run out or ctx is done. It reports whether
+// the last failure, if any, was one. A request already made is not cut
+// short by ctx, so that closing a backend can still send its last batch.
+func (s *httpSender) post(ctx context.Context, body []byte) (retry bool, err error) {
+	return s.retry.do(ctx, func() (bool, error) {
+		return s.postOnce(body)
+	})
+}
+
+// postOnce POSTs body, and reports whether a failure is worth retrying.
+// Network errors always are.
+func (s *httpSender) postOnce(body []byte) (retry bool, err error) {
+	req, err := http.NewRequest("POST", s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	for k, v := range s.header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return false, nil
+	}
+	return s.retryStatus(resp.StatusCode), fmt.Errorf("POST %s: %s", s.url, resp.Status)
+}
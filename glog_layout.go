@@ -0,0 +1,121 @@
+package glog
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+)
+
+// HeaderLayout configures the header written by ClassicEncoder; see
+// header for the fields. The zero HeaderLayout is the default.
+type HeaderLayout struct {
+	// Year adds the year before the month.
+	Year bool
+	// UTC writes the time in UTC rather than local time.
+	UTC bool
+	// Nanoseconds writes nine fractional digits of the seconds, not six.
+	Nanoseconds bool
+	// TimeZone adds the offset of the time zone after the time.
+	TimeZone bool
+	// ThreadID selects the thread ID written before the file name.
+	ThreadID ThreadIDStyle
+	// Path selects how the file name is written.
+	Path PathStyle
+	// Function adds the name of the calling function after the line number.
+	Function bool
+}
+
+// CPPHeaderLayout matches the header written by the C++ implementation,
+// in which the thread ID is the process ID.
+var CPPHeaderLayout = HeaderLayout{ThreadID: ThreadIDProcess}
+
+// ThreadIDStyle selects the thread ID in the header.
+type ThreadIDStyle int
+
+const (
+	// ThreadIDNone leaves out the thread ID.
+	ThreadIDNone ThreadIDStyle = iota
+	// ThreadIDProcess writes the process ID, padded with spaces to seven
+	// characters, as Go programs have no stable thread IDs.
+	ThreadIDProcess
+	// ThreadIDGoroutine writes the ID of the logging goroutine. Finding it
+	// costs a call to runtime.Stack for each line.
+	ThreadIDGoroutine
+)
+
+// PathStyle selects how the file name is written in the header.
+type PathStyle int
+
+const (
+	// PathBase writes the base name of the file.
+	PathBase PathStyle = iota
+	// PathRelative writes the path of the file relative to the working
+	// directory of the program, or the full path if it is not within it.
+	PathRelative
+	// PathFull writes the full path of the file, as compiled.
+	PathFull
+)
+
+// format returns file, a full path, in the style p.
+func (p PathStyle) format(file string) string {
+	switch p {
+	case PathFull:
+		return file
+	case PathRelative:
+		if workingDir != "" && strings.HasPrefix(file, workingDir) {
+			return file[len(workingDir):]
+		}
+		return file
+	}
+	if slash := strings.LastIndex(file, "/"); slash >= 0 {
+		return file[slash+1:]
+	}
+	return file
+}
+
+var (
+	// layout holds the current *HeaderLayout.
+	layout atomic.Value
+	pid    = os.Getpid()
+	// workingDir is the working directory at startup, in the form of the
+	// paths recorded by the compiler, with a trailing slash.
+	workingDir = func() string {
+		wd, err := os.Getwd()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSuffix(filepath.ToSlash(wd), "/") + "/"
+	}()
+)
+
+func init() {
+	layout.Store(&HeaderLayout{})
+}
+
+// SetHeaderLayout sets the layout of the classic header, and returns the
+// previous one.
+func SetHeaderLayout(h HeaderLayout) HeaderLayout {
+	return *layout.Swap(&h).(*HeaderLayout)
+}
+
+func currentLayout() *HeaderLayout {
+	return layout.Load().(*HeaderLayout)
+}
+
+// goroutineID writes the ID of the calling goroutine at the start of b,
+// using the rest of b as scratch space, and returns its length.
+func goroutineID(b []byte) int {
+	const prefix = "goroutine "
+	n := runtime.Stack(b, false)
+	end := len(prefix)
+	for end < n && b[end] >= '0' && b[end] <= '9' {
+		end++
+	}
+	if end == len(prefix) {
+		b[0] = '?'
+		return 1
+	}
+	return copy(b, b[len(prefix):end])
+}
@@ -0,0 +1,60 @@
+package glog
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHeaderLayout(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetHeaderLayout(SetHeaderLayout(HeaderLayout{}))
+	defer func(previous func() time.Time) { timeNow = previous }(timeNow)
+	timeNow = func() time.Time {
+		return time.Date(2006, 1, 2, 15, 4, 5, 678901234, time.FixedZone("MST", -7*3600))
+	}
+
+	tests := []struct {
+		layout HeaderLayout
+		want   string
+	}{
+		{
+			CPPHeaderLayout,
+			fmt.Sprintf(`I0102 15:04:05.678901 %7d glog_layout_test.go:\d+\] layout`, pid),
+		},
+		{
+			HeaderLayout{Year: true, UTC: true, Nanoseconds: true, TimeZone: true},
+			`I20060102 22:04:05.678901234 \+0000 glog_layout_test.go:\d+\] layout`,
+		},
+		{
+			HeaderLayout{TimeZone: true, ThreadID: ThreadIDGoroutine, Function: true},
+			`I0102 15:04:05.678901 -0700 \d+ glog_layout_test.go:\d+ [^ ]+\.TestHeaderLayout\] layout`,
+		},
+		{
+			HeaderLayout{Path: PathRelative},
+			`I0102 15:04:05.678901 glog_layout_test.go:\d+\] layout`,
+		},
+		{
+			HeaderLayout{Path: PathFull},
+			`I0102 15:04:05.678901 /.+/glog_layout_test.go:\d+\] layout`,
+		},
+	}
+	for _, tt := range tests {
+		fakeStdout.Reset()
+		SetHeaderLayout(tt.layout)
+		Info("layout")
+		if got := strings.TrimSuffix(contents(), "\n"); !regexp.MustCompile("^" + tt.want + "$").MatchString(got) {
+			t.Errorf("with %+v, got %q, want it to match %q", tt.layout, got, tt.want)
+		}
+	}
+}
+
+func TestGoroutineID(t *testing.T) {
+	var b [64]byte
+	n := goroutineID(b[:])
+	if !regexp.MustCompile(`^[1-9]\d*$`).Match(b[:n]) {
+		t.Errorf("got goroutine ID %q", b[:n])
+	}
+}
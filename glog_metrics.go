@@ -0,0 +1,175 @@
+package glog
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Metrics is a snapshot of the logging metrics, as returned by GetMetrics.
+type Metrics struct {
+	// Severities holds the output counts for each severity, from INFO
+	// to FATAL.
+	Severities []SeverityMetrics
+	// CallSites holds the number of times each logging call has been
+	// made, most frequent first.
+	CallSites []CallSiteMetrics
+	// Backends counts the events passed to backends.
+	Backends BackendMetrics
+	// WriteErrors counts the lines that could not be written to the output
+	// writer, as in Stats.WriteErrors.
+	WriteErrors OutputMetrics
+	// AsyncDropped counts the lines dropped by asynchronous output, as in
+	// Stats.AsyncDropped.
+	AsyncDropped OutputMetrics
+	// Truncated counts the lines cut to the size limits, as in
+	// Stats.Truncated.
+	Truncated OutputMetrics
+	// WriteLatency is a histogram of the time taken by each write to the
+	// output writer. With EnableAsync, a write may hold several lines.
+	WriteLatency Histogram
+}
+
+// OutputMetrics is a count of lines and bytes.
+type OutputMetrics struct {
+	Lines int64
+	Bytes int64
+}
+
+// SeverityMetrics is the count of lines and bytes logged at a severity.
+type SeverityMetrics struct {
+	Severity string
+	OutputMetrics
+}
+
+// CallSiteMetrics is the number of times a logging call has been made.
+type CallSiteMetrics struct {
+	File string
+	Line int
+	Hits int64
+}
+
+// BackendMetrics counts the events delivered to backends, those dropped
+// because a backend was not keeping up, and those a Backend failed to
+// handle.
+type BackendMetrics struct {
+	Delivered int64
+	Dropped   int64
+	Failed    int64
+}
+
+// Histogram is a distribution of durations.
+type Histogram struct {
+	// Bounds holds the upper bound of each bucket, in increasing order.
+	Bounds []time.Duration
+	// Counts holds the number of durations in each bucket, that is greater
+	// than the previous bound and no greater than its own. It has an extra
+	// bucket at the end for durations greater than the last bound.
+	Counts []int64
+	// Count is the total number of durations.
+	Count int64
+	// Sum is the total of all durations.
+	Sum time.Duration
+}
+
+// GetMetrics returns a snapshot of the logging metrics.
+func GetMetrics() Metrics {
+	m := Metrics{
+		Backends: BackendMetrics{
+			Delivered: atomic.LoadInt64(&backendStats.delivered),
+			Dropped:   atomic.LoadInt64(&backendStats.dropped),
+			Failed:    atomic.LoadInt64(&backendStats.failed),
+		},
+		WriteErrors:  Stats.WriteErrors.metrics(),
+		AsyncDropped: Stats.AsyncDropped.metrics(),
+		Truncated:    Stats.Truncated.metrics(),
+		WriteLatency: writeLatency.snapshot(),
+	}
+	for s, stats := range severityStats {
+		m.Severities = append(m.Severities, SeverityMetrics{
+			Severity:      severityName[s],
+			OutputMetrics: stats.metrics(),
+		})
+	}
+
+	// Different PCs may share a line, such as when a call is inlined.
+	type key struct {
+		file string
+		line int
+	}
+	hits := make(map[key]int64)
+	callSites.RLock()
+	for _, site := range callSites.m {
+		hits[key{site.file, site.line}] += atomic.LoadInt64(&site.hits)
+	}
+	callSites.RUnlock()
+	for k, n := range hits {
+		if n > 0 {
+			m.CallSites = append(m.CallSites, CallSiteMetrics{File: k.file, Line: k.line, Hits: n})
+		}
+	}
+	sort.Slice(m.CallSites, func(i, j int) bool {
+		a, b := m.CallSites[i], m.CallSites[j]
+		if a.Hits != b.Hits {
+			return a.Hits > b.Hits
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return m
+}
+
+func (s *OutputStats) metrics() OutputMetrics {
+	return OutputMetrics{Lines: s.Lines(), Bytes: s.Bytes()}
+}
+
+// latencyBounds are the bucket bounds of latency histograms.
+var latencyBounds = [...]time.Duration{
+	time.Microsecond,
+	5 * time.Microsecond,
+	10 * time.Microsecond,
+	50 * time.Microsecond,
+	100 * time.Microsecond,
+	500 * time.Microsecond,
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+}
+
+// latencyHistogram accumulates a Histogram with latencyBounds using atomic
+// ops, so it can be updated without a lock.
+type latencyHistogram struct {
+	counts [len(latencyBounds) + 1]int64
+	sum    int64
+}
+
+// writeLatency is the histogram of output writer latency.
+var writeLatency latencyHistogram
+
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBounds) && d > latencyBounds[i] {
+		i++
+	}
+	atomic.AddInt64(&h.counts[i], 1)
+	atomic.AddInt64(&h.sum, int64(d))
+}
+
+func (h *latencyHistogram) snapshot() Histogram {
+	s := Histogram{
+		Bounds: append([]time.Duration(nil), latencyBounds[:]...),
+		Counts: make([]int64, len(h.counts)),
+		Sum:    time.Duration(atomic.LoadInt64(&h.sum)),
+	}
+	for i := range h.counts {
+		s.Counts[i] = atomic.LoadInt64(&h.counts[i])
+		s.Count += s.Counts[i]
+	}
+	return s
+}
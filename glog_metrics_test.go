@@ -0,0 +1,55 @@
+package glog
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGetMetrics(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetExitFunc(SetExitFunc(func(int) {}))
+
+	before := GetMetrics()
+	var line int
+	for i := 0; i < 3; i++ {
+		_, _, line, _ = runtime.Caller(0)
+		Info("metrics")
+	}
+	Fatal("metrics")
+	after := GetMetrics()
+
+	if len(after.Severities) != numSeverity {
+		t.Fatalf("got %d severities, want %d", len(after.Severities), numSeverity)
+	}
+	for s, want := range []int64{3, 0, 0, 1} {
+		sm := after.Severities[s]
+		if sm.Severity != severityName[s] {
+			t.Errorf("got severity %s at %d, want %s", sm.Severity, s, severityName[s])
+		}
+		if got := sm.Lines - before.Severities[s].Lines; got != want {
+			t.Errorf("%s: got %d lines, want %d", sm.Severity, got, want)
+		}
+	}
+
+	if got := callSiteHits(after, line+1) - callSiteHits(before, line+1); got != 3 {
+		t.Errorf("got %d hits for the Info call, want 3: %v", got, after.CallSites)
+	}
+
+	if got := after.WriteLatency.Count - before.WriteLatency.Count; got < 4 {
+		t.Errorf("got %d write latencies, want at least 4", got)
+	}
+	if len(after.WriteLatency.Counts) != len(after.WriteLatency.Bounds)+1 {
+		t.Errorf("got %d latency buckets for %d bounds", len(after.WriteLatency.Counts), len(after.WriteLatency.Bounds))
+	}
+}
+
+// callSiteHits returns the hits in m for the given line of this file.
+func callSiteHits(m Metrics, line int) int64 {
+	for _, site := range m.CallSites {
+		if site.Line == line && strings.HasSuffix(site.File, "/glog_metrics_test.go") {
+			return site.Hits
+		}
+	}
+	return 0
+}
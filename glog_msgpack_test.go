@@ -0,0 +1,45 @@
+package glog
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMsgpackRoundTrip(t *testing.T) {
+	long := strings.Repeat("x", 300)
+	var enc msgpackEncoder
+	enc.writeArrayHeader(20)
+	enc.writeString("short")
+	enc.writeString(long)
+	for _, n := range []int64{0, 127, 128, 255, 256, 65535, 65536, 1 << 40, -1, -32, -33, -128, -129, -32768, -32769, -1 << 40} {
+		enc.writeInt(n)
+	}
+	enc.writeMapHeader(17)
+	for i := 0; i < 17; i++ {
+		enc.writeString(string(rune('a' + i)))
+		enc.writeInt(int64(i))
+	}
+	enc.writeEventTime(time.Unix(1136214245, 678901000))
+
+	got, err := newMsgpackDecoder(bytes.NewReader(enc.buf)).decode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := make(map[string]interface{})
+	for i := 0; i < 17; i++ {
+		m[string(rune('a'+i))] = int64(i)
+	}
+	want := []interface{}{
+		"short", long,
+		int64(0), int64(127), int64(128), int64(255), int64(256), int64(65535), int64(65536), int64(1 << 40),
+		int64(-1), int64(-32), int64(-33), int64(-128), int64(-129), int64(-32768), int64(-32769), int64(-1 << 40),
+		m,
+		msgpackExt{0, []byte{0x43, 0xb9, 0x40, 0xe5, 0x28, 0x77, 0x35, 0x08}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("decoded %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,110 @@
+package glog
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"sync/atomic"
+)
+
+// MultilinePolicy selects how messages that span several lines, such as
+// errors formatted with %+v, are written to the output. Backends always
+// receive the message as it was logged.
+type MultilinePolicy int32
+
+const (
+	// MultilineKeep writes continuation lines as they are, with no header.
+	// It is the default.
+	MultilineKeep MultilinePolicy = iota
+	// MultilineIndent indents continuation lines by the width of the
+	// header, so that they line up with the start of the message.
+	MultilineIndent
+	// MultilineHeader starts each continuation line with the header of
+	// the first.
+	MultilineHeader
+	// MultilineEscape writes the message on a single line, with each
+	// newline escaped as `\n`.
+	MultilineEscape
+)
+
+var multilinePolicyNames = []string{
+	MultilineKeep:   "keep",
+	MultilineIndent: "indent",
+	MultilineHeader: "header",
+	MultilineEscape: "escape",
+}
+
+// multilinePolicy is the current MultilinePolicy. Use atomic ops.
+var multilinePolicy int32
+
+func init() {
+	flag.Var(new(MultilinePolicy), "log_multiline", "how to write messages of several lines: keep, indent, header or escape")
+}
+
+// SetMultilinePolicy sets how messages of several lines are written, and
+// returns the previous policy.
+func SetMultilinePolicy(p MultilinePolicy) MultilinePolicy {
+	return MultilinePolicy(atomic.SwapInt32(&multilinePolicy, int32(p)))
+}
+
+// String implements flag.Value. The flag sets the policy for the program,
+// whatever the value of the MultilinePolicy.
+func (p *MultilinePolicy) String() string {
+	policy := atomic.LoadInt32(&multilinePolicy)
+	if policy < 0 || int(policy) >= len(multilinePolicyNames) {
+		return fmt.Sprint(policy)
+	}
+	return multilinePolicyNames[policy]
+}
+
+// Set implements flag.Value.
+func (p *MultilinePolicy) Set(value string) error {
+	for policy, name := range multilinePolicyNames {
+		if name == value {
+			*p = MultilinePolicy(policy)
+			SetMultilinePolicy(*p)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown multiline policy %q", value)
+}
+
+// endLine applies the multiline policy to the message in buf, which starts
+// at offset start, and terminates the line with a newline.
+func (l *loggingT) endLine(buf *buffer, start int) {
+	msg := bytes.TrimSuffix(buf.Bytes()[start:], []byte{'\n'})
+	policy := MultilinePolicy(atomic.LoadInt32(&multilinePolicy))
+	if policy == MultilineKeep || bytes.IndexByte(msg, '\n') < 0 {
+		buf.Truncate(start + len(msg))
+		buf.WriteByte('\n')
+		return
+	}
+
+	tmp := l.getBuffer()
+	tmp.Write(msg)
+	msg = tmp.Bytes()
+	buf.Truncate(start)
+	for {
+		i := bytes.IndexByte(msg, '\n')
+		if i < 0 {
+			buf.Write(msg)
+			break
+		}
+		buf.Write(msg[:i])
+		msg = msg[i+1:]
+		switch policy {
+		case MultilineIndent:
+			buf.WriteByte('\n')
+			for j := 0; j < start; j++ {
+				buf.WriteByte(' ')
+			}
+		case MultilineHeader:
+			buf.WriteByte('\n')
+			buf.Write(buf.Bytes()[:start])
+		case MultilineEscape:
+			buf.WriteString(`\n`)
+		}
+	}
+	buf.WriteByte('\n')
+	l.putBuffer(tmp)
+}
@@ -0,0 +1,69 @@
+package glog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMultilinePolicy(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetMultilinePolicy(SetMultilinePolicy(MultilineKeep))
+
+	tests := []struct {
+		policy MultilinePolicy
+		want   func(header string) string
+	}{
+		{MultilineKeep, func(h string) string { return h + "one\ntwo\n\nthree\n" }},
+		{MultilineIndent, func(h string) string {
+			indent := strings.Repeat(" ", len(h))
+			return h + "one\n" + indent + "two\n" + indent + "\n" + indent + "three\n"
+		}},
+		{MultilineHeader, func(h string) string { return h + "one\n" + h + "two\n" + h + "\n" + h + "three\n" }},
+		{MultilineEscape, func(h string) string { return h + `one\ntwo\n\nthree` + "\n" }},
+	}
+	for _, tt := range tests {
+		SetMultilinePolicy(tt.policy)
+		for _, log := range []func(){
+			func() { Info("one\ntwo\n\nthree") },
+			func() { Infoln("one\ntwo\n\nthree") },
+			func() { Infof("one\n%s\n\nthree\n", "two") },
+		} {
+			fakeStdout.Reset()
+			log()
+			got := contents()
+			header := got[:strings.Index(got, "] ")+2]
+			if want := tt.want(header); got != want {
+				t.Errorf("policy %d: got %q, want %q", tt.policy, got, want)
+			}
+		}
+	}
+}
+
+func TestMultilineEvent(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetMultilinePolicy(SetMultilinePolicy(MultilineEscape))
+	c := make(chan Event, 10)
+	b := ChanBackend(c)
+	AddBackend(b)
+	defer RemoveBackend(testContext(t), b)
+
+	Info("one\ntwo")
+	FlushBackends(testContext(t))
+	if e := <-c; !strings.HasSuffix(string(e.Message), "] one\ntwo") {
+		t.Errorf("event message changed: %q", e.Message)
+	}
+}
+
+func TestMultilineFlag(t *testing.T) {
+	defer SetMultilinePolicy(SetMultilinePolicy(MultilineKeep))
+	var p MultilinePolicy
+	if err := p.Set("header"); err != nil || p.String() != "header" {
+		t.Errorf("Set(header) gave error %v, policy %s", err, p.String())
+	}
+	if err := p.Set("fold"); err == nil {
+		t.Error("Set(fold) did not fail")
+	}
+	if p.String() != "header" {
+		t.Errorf("policy changed to %s after an error", p.String())
+	}
+}
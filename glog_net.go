@@ -0,0 +1,69 @@
+package glog
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// netSender writes to a network address for the backends that send each
+// event as it is handled. The connection is made on first use, and remade
+// if a write fails.
+type netSender struct {
+	network string
+	addr    string
+	timeout time.Duration // bounds dialing and each write
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// send writes each packet, reconnecting once if need be. Over datagram
+// networks, each packet is a datagram.
+func (s *netSender) send(packets ...[]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.write(packets)
+	if err != nil {
+		// The server may have gone away; try again on a new connection.
+		s.closeConn()
+		err = s.write(packets)
+	}
+	return err
+}
+
+// close closes the connection, if any.
+func (s *netSender) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeConn()
+}
+
+// write writes each packet, dialing first if there is no connection.
+// s.mu is held.
+func (s *netSender) write(packets [][]byte) error {
+	if s.conn == nil {
+		conn, err := net.DialTimeout(s.network, s.addr, s.timeout)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+	}
+	s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+	for _, p := range packets {
+		if _, err := s.conn.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// s.mu is held.
+func (s *netSender) closeConn() error {
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,291 @@
+package glog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// TraceContext identifies the trace and span a logging call was made in,
+// so that backends can correlate events with traces. Pass it as data:
+//
+//	sc := span.SpanContext()
+//	l := glog.WithData(glog.TraceContext{
+//		TraceID: sc.TraceID().String(),
+//		SpanID:  sc.SpanID().String(),
+//		Flags:   byte(sc.TraceFlags()),
+//	})
+type TraceContext struct {
+	// TraceID and SpanID are the IDs in lowercase hex, of 32 and 16
+	// digits.
+	TraceID string
+	SpanID  string
+	// Flags holds the W3C trace flags, such as 1 for a sampled trace.
+	Flags byte
+}
+
+// OTLPOptions configures an OTLPBackend.
+type OTLPOptions struct {
+	// Endpoint is the URL to which logs are exported. It defaults to
+	// "http://localhost:4318/v1/logs", the OTLP/HTTP logs endpoint of a
+	// local collector.
+	Endpoint string
+	// Client defaults to an http.Client with a 10 second timeout.
+	Client *http.Client
+	// Header holds extra headers to send with each request, such as
+	// for authorization.
+	Header http.Header
+	// ServiceName is the service.name attribute of the resource. It
+	// defaults to the name of the program.
+	ServiceName string
+	// ResourceAttributes are further attributes of the resource, such as
+	// "deployment.environment".
+	ResourceAttributes map[string]string
+	// BatchSize and BatchInterval are as for WebhookOptions, but default
+	// to 512 events and 1 second.
+	BatchSize     int
+	BatchInterval time.Duration
+	// MaxRetries, MinBackoff and MaxBackoff bound the retries of a failed
+	// export, as for WebhookOptions.
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+}
+
+// OTLPBackend exports events to an OpenTelemetry collector, in batches,
+// using OTLP/HTTP with JSON encoding. Each event becomes a log record
+// whose body is the message, less the classic header. Its attributes
+// come from Event.Data, as for dataFields, with an ErrorArg giving
+// "exception.message" and "exception.type", the type of its root cause.
+// The logging call is given by "code.function", "code.filepath" and
+// "code.lineno", and the fingerprint by "glog.fingerprint". A
+// TraceContext sets the trace and span of the record.
+type OTLPBackend struct {
+	opts     OTLPOptions
+	resource otlpResource
+	sender   *httpSender
+	batcher  *batcher
+}
+
+// NewOTLPBackend creates an OTLPBackend. To export every event:
+//
+//	glog.AddBackend(glog.NewOTLPBackend(glog.OTLPOptions{ServiceName: "api"}))
+func NewOTLPBackend(opts OTLPOptions) *OTLPBackend {
+	if opts.Endpoint == "" {
+		opts.Endpoint = "http://localhost:4318/v1/logs"
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.ServiceName == "" {
+		opts.ServiceName = filepath.Base(os.Args[0])
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 512
+	}
+	if opts.BatchInterval <= 0 {
+		opts.BatchInterval = time.Second
+	}
+
+	b := &OTLPBackend{opts: opts}
+	b.sender = &httpSender{
+		url:         opts.Endpoint,
+		client:      opts.Client,
+		header:      opts.Header,
+		retry:       newRetryPolicy(opts.MaxRetries, opts.MinBackoff, opts.MaxBackoff),
+		retryStatus: otlpRetryStatus,
+	}
+	b.resource.Attributes = append(b.resource.Attributes, otlpString("service.name", opts.ServiceName))
+	keys := make([]string, 0, len(opts.ResourceAttributes))
+	for k := range opts.ResourceAttributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		b.resource.Attributes = append(b.resource.Attributes, otlpString(k, opts.ResourceAttributes[k]))
+	}
+	b.batcher = newBatcher(opts.BatchSize, opts.BatchInterval, b.export)
+	return b
+}
+
+// Handle adds e to the current batch. Errors exporting batches are
+// reported to the backend error handler as they happen, so it always
+// returns nil.
+func (b *OTLPBackend) Handle(e Event) error {
+	b.batcher.add(e)
+	return nil
+}
+
+// Flush exports the current batch without waiting for it to fill, and
+// returns the error from exporting it.
+func (b *OTLPBackend) Flush(ctx context.Context) error {
+	return b.batcher.flush(ctx)
+}
+
+// Close exports the current batch and stops the backend.
+func (b *OTLPBackend) Close() error {
+	b.batcher.close()
+	return nil
+}
+
+// The types below are the JSON form of the OTLP logs data model, as in
+// opentelemetry/proto/collector/logs/v1/logs_service.proto. 64-bit
+// integers are encoded as strings, as for the JSON mapping of protobuf.
+
+type otlpRequest struct {
+	ResourceLogs []otlpResourceLogs `json:"resourceLogs"`
+}
+
+type otlpResourceLogs struct {
+	Resource  otlpResource    `json:"resource"`
+	ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeLogs struct {
+	Scope      otlpScope       `json:"scope"`
+	LogRecords []otlpLogRecord `json:"logRecords"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpLogRecord struct {
+	TimeUnixNano         string         `json:"timeUnixNano"`
+	ObservedTimeUnixNano string         `json:"observedTimeUnixNano"`
+	SeverityNumber       int            `json:"severityNumber"`
+	SeverityText         string         `json:"severityText"`
+	Body                 otlpAnyValue   `json:"body"`
+	Attributes           []otlpKeyValue `json:"attributes,omitempty"`
+	TraceID              string         `json:"traceId,omitempty"`
+	SpanID               string         `json:"spanId,omitempty"`
+	Flags                byte           `json:"flags,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    string  `json:"intValue,omitempty"`
+}
+
+func otlpString(key, value string) otlpKeyValue {
+	return otlpKeyValue{key, otlpAnyValue{StringValue: &value}}
+}
+
+func otlpInt(key string, value int64) otlpKeyValue {
+	return otlpKeyValue{key, otlpAnyValue{IntValue: strconv.FormatInt(value, 10)}}
+}
+
+// otlpScopeName is the instrumentation scope of the records: this package.
+const otlpScopeName = "github.com/yext/glog"
+
+// otlpSeverity holds the OTel severity number for each severity.
+var otlpSeverity = map[string]int{
+	"INFO":    9,
+	"WARNING": 13,
+	"ERROR":   17,
+	"FATAL":   21,
+}
+
+func newOTLPLogRecord(e Event, observed time.Time) otlpLogRecord {
+	body := e.text()
+	r := otlpLogRecord{
+		ObservedTimeUnixNano: strconv.FormatInt(observed.UnixNano(), 10),
+		SeverityNumber:       otlpSeverity[e.Severity],
+		SeverityText:         e.Severity,
+		Body:                 otlpAnyValue{StringValue: &body},
+	}
+	if !e.Time.IsZero() {
+		r.TimeUnixNano = strconv.FormatInt(e.Time.UnixNano(), 10)
+	} else {
+		r.TimeUnixNano = r.ObservedTimeUnixNano
+	}
+	if e.PC != 0 {
+		site := siteForPC(e.PC)
+		r.Attributes = append(r.Attributes,
+			otlpString("code.function", site.function),
+			otlpString("code.filepath", site.file),
+			otlpInt("code.lineno", int64(site.line)))
+	}
+	if e.Fingerprint != "" {
+		r.Attributes = append(r.Attributes, otlpString("glog.fingerprint", e.Fingerprint))
+	}
+
+	var rest []interface{}
+	exception := false
+	for _, d := range e.Data {
+		switch d := d.(type) {
+		case TraceContext:
+			r.TraceID, r.SpanID, r.Flags = d.TraceID, d.SpanID, d.Flags
+		case ErrorArg:
+			if exception || d.Error == nil {
+				rest = append(rest, d)
+				continue
+			}
+			exception = true
+			r.Attributes = append(r.Attributes, otlpString("exception.message", d.Error.Error()))
+			if root := d.RootCause(); root != nil {
+				r.Attributes = append(r.Attributes, otlpString("exception.type", fmt.Sprintf("%T", root)))
+			}
+		default:
+			rest = append(rest, d)
+		}
+	}
+	for _, f := range dataFields(rest) {
+		r.Attributes = append(r.Attributes, otlpString(f.key, f.value))
+	}
+	return r
+}
+
+// export sends a batch of events, if any, to the collector.
+func (b *OTLPBackend) export(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	now := time.Now()
+	records := make([]otlpLogRecord, len(events))
+	for i, e := range events {
+		records[i] = newOTLPLogRecord(e, now)
+	}
+	body, err := json.Marshal(otlpRequest{[]otlpResourceLogs{{
+		Resource: b.resource,
+		ScopeLogs: []otlpScopeLogs{{
+			Scope:      otlpScope{Name: otlpScopeName},
+			LogRecords: records,
+		}},
+	}}})
+	if err != nil {
+		reportBackendError(b, err)
+		return err
+	}
+
+	if _, err := b.sender.post(ctx, body); err != nil {
+		reportBackendError(b, err)
+		return err
+	}
+	return nil
+}
+
+// otlpRetryStatus reports whether an export that failed with the given
+// status is worth retrying, as the OTLP specification defines it.
+func otlpRetryStatus(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
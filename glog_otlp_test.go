@@ -0,0 +1,179 @@
+package glog
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// otlpCollector stands in for an OpenTelemetry collector, recording the
+// requests exported to it and failing with the status returned by fail
+// while it is non-zero.
+type otlpCollector struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	fail     func() int
+	requests int
+	exports  []otlpRequest
+}
+
+func newOTLPCollector(t *testing.T) *otlpCollector {
+	c := &otlpCollector{}
+	c.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		c.requests++
+		if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected request to %s with content type %q", r.URL.Path, r.Header.Get("Content-Type"))
+		}
+		if c.fail != nil {
+			if code := c.fail(); code != 0 {
+				w.WriteHeader(code)
+				return
+			}
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		c.exports = append(c.exports, req)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+	}))
+	return c
+}
+
+func (c *otlpCollector) got() (requests int, exports []otlpRequest) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests, c.exports
+}
+
+// attributes returns the string and int attributes of a record as strings.
+func (r otlpLogRecord) attributes() map[string]string {
+	m := make(map[string]string)
+	for _, kv := range r.Attributes {
+		if kv.Value.StringValue != nil {
+			m[kv.Key] = *kv.Value.StringValue
+		} else {
+			m[kv.Key] = kv.Value.IntValue
+		}
+	}
+	return m
+}
+
+func TestOTLPBackend(t *testing.T) {
+	defer resetOutput(setBuffer())
+	c := newOTLPCollector(t)
+	defer c.Close()
+
+	b := NewOTLPBackend(OTLPOptions{
+		Endpoint:           c.URL + "/v1/logs",
+		ServiceName:        "api",
+		ResourceAttributes: map[string]string{"deployment.environment": "test"},
+		BatchInterval:      time.Hour,
+	})
+	AddBackend(b)
+	defer RemoveBackend(testContext(t), b)
+
+	trace := TraceContext{TraceID: "5b8efff798038103d269b633813fc60c", SpanID: "eee19b7ec3c1b174", Flags: 1}
+	Errorf("failed: %v", errors.New("bad thing"), Data(trace), Data(map[string]string{"user": "alice"}))
+	Info("done")
+	if err := FlushBackends(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, exports := c.got()
+	if len(exports) != 1 || len(exports[0].ResourceLogs) != 1 {
+		t.Fatalf("got %d exports, want 1: %+v", len(exports), exports)
+	}
+	rl := exports[0].ResourceLogs[0]
+	resource := otlpLogRecord{Attributes: rl.Resource.Attributes}.attributes()
+	if resource["service.name"] != "api" || resource["deployment.environment"] != "test" {
+		t.Errorf("unexpected resource attributes %v", resource)
+	}
+	if len(rl.ScopeLogs) != 1 || len(rl.ScopeLogs[0].LogRecords) != 2 {
+		t.Fatalf("unexpected scope logs %+v", rl.ScopeLogs)
+	}
+	if name := rl.ScopeLogs[0].Scope.Name; name != "github.com/yext/glog" {
+		t.Errorf("scope is %q, want github.com/yext/glog", name)
+	}
+
+	r := rl.ScopeLogs[0].LogRecords[0]
+	if r.SeverityNumber != 17 || r.SeverityText != "ERROR" || *r.Body.StringValue != "failed: bad thing" {
+		t.Errorf("unexpected record %+v", r)
+	}
+	if r.TraceID != trace.TraceID || r.SpanID != trace.SpanID || r.Flags != 1 {
+		t.Errorf("unexpected trace context %q %q %d", r.TraceID, r.SpanID, r.Flags)
+	}
+	if r.TimeUnixNano == "" || r.TimeUnixNano == "0" {
+		t.Errorf("no time in record %+v", r)
+	}
+	attrs := r.attributes()
+	for k, want := range map[string]string{
+		"exception.message": "bad thing",
+		"exception.type":    "*errors.errorString",
+		"format":            "failed: %v",
+		"user":              "alice",
+	} {
+		if attrs[k] != want {
+			t.Errorf("attribute %s is %q, want %q", k, attrs[k], want)
+		}
+	}
+	if !strings.HasSuffix(attrs["code.filepath"], "glog_otlp_test.go") || attrs["code.lineno"] == "" ||
+		!strings.HasSuffix(attrs["code.function"], "TestOTLPBackend") || attrs["glog.fingerprint"] == "" {
+		t.Errorf("unexpected code attributes %v", attrs)
+	}
+
+	r = rl.ScopeLogs[0].LogRecords[1]
+	if r.SeverityNumber != 9 || *r.Body.StringValue != "done" || r.TraceID != "" {
+		t.Errorf("unexpected record %+v", r)
+	}
+}
+
+func TestOTLPRetry(t *testing.T) {
+	c := newOTLPCollector(t)
+	defer c.Close()
+	failures := 2
+	c.fail = func() int {
+		if failures > 0 {
+			failures--
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	}
+
+	b := NewOTLPBackend(OTLPOptions{Endpoint: c.URL + "/v1/logs", MinBackoff: time.Millisecond})
+	b.Handle(webhookTestEvent(0))
+	b.Close()
+
+	if requests, exports := c.got(); requests != 3 || len(exports) != 1 {
+		t.Errorf("got %d requests and %d exports, want 3 and 1", requests, len(exports))
+	}
+}
+
+func TestOTLPNoRetryOnBadRequest(t *testing.T) {
+	c := newOTLPCollector(t)
+	defer c.Close()
+	c.fail = func() int { return http.StatusBadRequest }
+
+	b := NewOTLPBackend(OTLPOptions{Endpoint: c.URL + "/v1/logs", MinBackoff: time.Millisecond})
+	var reported error
+	defer SetBackendErrorHandler(SetBackendErrorHandler(func(_ Backend, err error) { reported = err }))
+	b.Handle(webhookTestEvent(0))
+	b.Close()
+
+	if requests, _ := c.got(); requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+	if reported == nil {
+		t.Error("failure not reported")
+	}
+}
@@ -0,0 +1,162 @@
+package glog
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+)
+
+// OutputFlush determines when an output is flushed, by calling its
+// 'Flush() error' and 'Sync() error' methods if it has them.
+type OutputFlush int
+
+const (
+	// FlushPeriodic flushes the output every 30 seconds and on Flush.
+	// This is the default.
+	FlushPeriodic OutputFlush = iota
+	// FlushEachLine also flushes the output after every write.
+	FlushEachLine
+	// FlushNever never flushes the output.
+	FlushNever
+)
+
+// OutputOptions configures an output added with AddOutput.
+type OutputOptions struct {
+	// MinSeverity is the least severity written to the output: "INFO",
+	// the default, "WARNING", "ERROR" or "FATAL".
+	MinSeverity string
+	// Encoder renders lines for the output. If nil, the encoder set with
+	// SetEncoder is used.
+	Encoder Encoder
+	// Flush determines when the output is flushed.
+	Flush OutputFlush
+}
+
+// structuredOutputs is the number of outputs with a structured encoder of
+// their own. Use atomic ops.
+var structuredOutputs int32
+
+// outputEncoder is an encoder of outputs with one of their own, and the
+// least severity written to any of them.
+type outputEncoder struct {
+	enc Encoder
+	min severity
+}
+
+// outputEncoders holds a []outputEncoder for the outputs, so that lines
+// can be rendered for them without holding logging.mu.
+var outputEncoders atomic.Value
+
+// AddOutput adds w as an output alongside the primary one set by
+// SetOutput, so that log lines can go to several places in different
+// forms, as in:
+//
+//	glog.AddOutput(file, glog.OutputOptions{Encoder: glog.JSONEncoder})
+//
+// It panics if opts.MinSeverity is not a severity. Lines are written to
+// the outputs in the order they were added, after the primary output.
+// Write errors are handled for each as for the primary output.
+func AddOutput(w io.Writer, opts OutputOptions) {
+	o := newWriter(w)
+	if opts.MinSeverity != "" {
+		s, ok := severityByName(strings.ToUpper(opts.MinSeverity))
+		if !ok {
+			panic(fmt.Sprintf("glog: unknown severity %q", opts.MinSeverity))
+		}
+		o.min = s
+	}
+	o.enc = opts.Encoder
+	o.flush = opts.Flush
+
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	outputs := make([]*writer, len(logging.outputs), len(logging.outputs)+1)
+	copy(outputs, logging.outputs)
+	logging.setOutputs(append(outputs, o))
+}
+
+// RemoveOutput stops writing to w, once the lines already logged have
+// been written, and flushes it. It does nothing if w was not added with
+// AddOutput; use SetOutput to replace the primary output. Outputs are
+// compared with ==, so w must be of a comparable type, such as a pointer.
+func RemoveOutput(w io.Writer) {
+	logging.mu.Lock()
+	defer logging.mu.Unlock()
+	outputs := make([]*writer, 1, len(logging.outputs))
+	outputs[0] = logging.outputs[0]
+	var removed []*writer
+	for _, o := range logging.outputs[1:] {
+		if o.Writer == w {
+			removed = append(removed, o)
+		} else {
+			outputs = append(outputs, o)
+		}
+	}
+	if removed == nil {
+		return
+	}
+	logging.setOutputs(outputs)
+	if logging.async != nil {
+		logging.async.wait()
+	}
+	for _, o := range removed {
+		if o.flush != FlushNever {
+			o.Flush()
+		}
+	}
+}
+
+// flushLine flushes w after a line is written, if its policy says to.
+func (w *writer) flushLine() {
+	if w.flush == FlushEachLine {
+		w.Flush()
+	}
+}
+
+// encoder returns the encoder of w, which is def if it has none of its own.
+func (w *writer) encoder(def Encoder) Encoder {
+	if w.enc != nil {
+		return w.enc
+	}
+	return def
+}
+
+// setOutput replaces the primary output with w.
+// l.mu is held.
+func (l *loggingT) setOutput(w *writer) {
+	outputs := make([]*writer, len(l.outputs))
+	copy(outputs, l.outputs)
+	outputs[0] = w
+	l.setOutputs(outputs)
+}
+
+// setOutputs replaces the outputs, which must not be changed afterwards.
+// l.mu is held.
+func (l *loggingT) setOutputs(outputs []*writer) {
+	var (
+		n    int32
+		encs []outputEncoder
+	)
+outputs:
+	for _, w := range outputs {
+		if w.enc == nil {
+			continue
+		}
+		if w.enc.structured() {
+			n++
+		}
+		for i := range encs {
+			if encs[i].enc == w.enc {
+				if w.min < encs[i].min {
+					encs[i].min = w.min
+				}
+				continue outputs
+			}
+		}
+		encs = append(encs, outputEncoder{w.enc, w.min})
+	}
+	l.outputs = outputs
+	outputEncoders.Store(encs)
+	atomic.StoreInt32(&structuredOutputs, n)
+}
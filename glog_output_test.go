@@ -0,0 +1,148 @@
+package glog
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+// flushRecorder is a bytes.Buffer that counts calls to Flush.
+type flushRecorder struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (f *flushRecorder) Flush() error {
+	f.flushes++
+	return nil
+}
+
+func TestOutputs(t *testing.T) {
+	defer resetOutput(setBuffer())
+	var jsonOut, warnings bytes.Buffer
+	AddOutput(&jsonOut, OutputOptions{Encoder: JSONEncoder})
+	defer RemoveOutput(&jsonOut)
+	AddOutput(&warnings, OutputOptions{MinSeverity: "warning"})
+	defer RemoveOutput(&warnings)
+
+	Info("one")
+	Warningf("two %d", 2)
+	if got := contents(); !strings.Contains(got, "] one\n") || !strings.Contains(got, "] two 2\n") || strings.Contains(got, "{") {
+		t.Errorf("unexpected primary output: %q", got)
+	}
+	if got := warnings.String(); strings.Contains(got, "one") || !strings.HasPrefix(got, "W") || !strings.HasSuffix(got, "] two 2\n") {
+		t.Errorf("unexpected warnings output: %q", got)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(jsonOut.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d JSON lines, want 2:\n%s", len(lines), jsonOut.String())
+	}
+	for i, want := range []string{"one", "two 2"} {
+		var m map[string]string
+		if err := json.Unmarshal([]byte(lines[i]), &m); err != nil {
+			t.Errorf("line %q is not JSON: %v", lines[i], err)
+		} else if m["msg"] != want {
+			t.Errorf("got message %q, want %q", m["msg"], want)
+		}
+	}
+
+	RemoveOutput(&warnings)
+	Warning("three")
+	if strings.Contains(warnings.String(), "three") {
+		t.Error("removed output written to")
+	}
+	if !strings.Contains(contents(), "] three\n") {
+		t.Error("primary output not written to")
+	}
+}
+
+// loggingStringer logs when it is formatted, and counts the times it is.
+type loggingStringer struct {
+	calls int
+}
+
+func (l *loggingStringer) String() string {
+	l.calls++
+	Info("inside String")
+	return "stringer"
+}
+
+func TestOutputsFormatOnce(t *testing.T) {
+	defer resetOutput(setBuffer())
+	var jsonOut bytes.Buffer
+	AddOutput(&jsonOut, OutputOptions{Encoder: JSONEncoder})
+	defer RemoveOutput(&jsonOut)
+
+	// Formatting an argument that logs must not deadlock, whatever the
+	// encoders of the outputs.
+	s := &loggingStringer{}
+	done := make(chan struct{})
+	go func() {
+		Infof("%v", s)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("logging an argument that logs deadlocked")
+	}
+	if s.calls != 1 {
+		t.Errorf("argument formatted %d times, want once", s.calls)
+	}
+	if !strings.Contains(contents(), "] stringer\n") || !strings.Contains(jsonOut.String(), `"msg":"stringer"`) {
+		t.Errorf("unexpected output %q and %q", contents(), jsonOut.String())
+	}
+}
+
+func TestOutputFlush(t *testing.T) {
+	defer resetOutput(setBuffer())
+	var eachLine, never flushRecorder
+	AddOutput(&eachLine, OutputOptions{Flush: FlushEachLine})
+	AddOutput(&never, OutputOptions{Flush: FlushNever})
+	defer RemoveOutput(&never)
+
+	Info("one")
+	Info("two")
+	if eachLine.flushes != 2 {
+		t.Errorf("flushed %d times after two lines, want 2", eachLine.flushes)
+	}
+	Flush()
+	if eachLine.flushes != 3 || never.flushes != 0 {
+		t.Errorf("flushed %d and %d times by Flush, want 3 and 0", eachLine.flushes, never.flushes)
+	}
+	RemoveOutput(&eachLine)
+	if eachLine.flushes != 4 {
+		t.Errorf("flushed %d times on removal, want 4", eachLine.flushes)
+	}
+}
+
+func TestOutputsAsync(t *testing.T) {
+	defer resetOutput(setBuffer())
+	var jsonOut bytes.Buffer
+	AddOutput(&jsonOut, OutputOptions{Encoder: JSONEncoder})
+	EnableAsync(AsyncOptions{})
+	defer DisableAsync()
+
+	Info("one")
+	Info("two")
+	RemoveOutput(&jsonOut)
+	Flush()
+	if got := strings.Count(jsonOut.String(), `"msg":`); got != 2 {
+		t.Errorf("got %d JSON lines, want 2: %q", got, jsonOut.String())
+	}
+	if got := strings.Count(contents(), "] "); got != 2 {
+		t.Errorf("got %d lines, want 2: %q", got, contents())
+	}
+}
+
+func TestAddOutputUnknownSeverity(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("AddOutput did not panic")
+		}
+	}()
+	AddOutput(&bytes.Buffer{}, OutputOptions{MinSeverity: "loud"})
+}
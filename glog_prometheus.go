@@ -0,0 +1,69 @@
+package glog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// MetricsHandler returns an http.Handler that serves the logging metrics
+// returned by GetMetrics in the Prometheus text exposition format:
+//
+//	http.Handle("/metrics/glog", glog.MetricsHandler())
+func MetricsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		writePrometheus(w, GetMetrics())
+	})
+}
+
+// writePrometheus writes m to w in the Prometheus text exposition format.
+func writePrometheus(w io.Writer, m Metrics) error {
+	b := bufio.NewWriter(w)
+
+	promHeader(b, "glog_lines_total", "counter", "Number of lines logged, by severity.")
+	for _, s := range m.Severities {
+		fmt.Fprintf(b, "glog_lines_total{severity=%q} %d\n", s.Severity, s.Lines)
+	}
+	promHeader(b, "glog_bytes_total", "counter", "Number of bytes logged, by severity.")
+	for _, s := range m.Severities {
+		fmt.Fprintf(b, "glog_bytes_total{severity=%q} %d\n", s.Severity, s.Bytes)
+	}
+
+	promHeader(b, "glog_backend_events_delivered_total", "counter", "Number of events delivered to backends.")
+	fmt.Fprintf(b, "glog_backend_events_delivered_total %d\n", m.Backends.Delivered)
+	promHeader(b, "glog_backend_events_dropped_total", "counter", "Number of events dropped because a backend was not keeping up.")
+	fmt.Fprintf(b, "glog_backend_events_dropped_total %d\n", m.Backends.Dropped)
+	promHeader(b, "glog_backend_events_failed_total", "counter", "Number of events a backend failed to handle.")
+	fmt.Fprintf(b, "glog_backend_events_failed_total %d\n", m.Backends.Failed)
+
+	promHeader(b, "glog_write_errors_total", "counter", "Number of lines that could not be written to the output.")
+	fmt.Fprintf(b, "glog_write_errors_total %d\n", m.WriteErrors.Lines)
+	promHeader(b, "glog_async_dropped_total", "counter", "Number of lines dropped because the asynchronous output queue was full.")
+	fmt.Fprintf(b, "glog_async_dropped_total %d\n", m.AsyncDropped.Lines)
+	promHeader(b, "glog_truncated_total", "counter", "Number of lines cut to the size limits.")
+	fmt.Fprintf(b, "glog_truncated_total %d\n", m.Truncated.Lines)
+
+	h := m.WriteLatency
+	promHeader(b, "glog_write_duration_seconds", "histogram", "Time taken to write to the output.")
+	var cumulative int64
+	for i, bound := range h.Bounds {
+		cumulative += h.Counts[i]
+		fmt.Fprintf(b, "glog_write_duration_seconds_bucket{le=%q} %d\n", promFloat(bound.Seconds()), cumulative)
+	}
+	fmt.Fprintf(b, "glog_write_duration_seconds_bucket{le=\"+Inf\"} %d\n", h.Count)
+	fmt.Fprintf(b, "glog_write_duration_seconds_sum %s\n", promFloat(h.Sum.Seconds()))
+	fmt.Fprintf(b, "glog_write_duration_seconds_count %d\n", h.Count)
+
+	return b.Flush()
+}
+
+func promHeader(w io.Writer, name, typ, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func promFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
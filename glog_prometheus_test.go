@@ -0,0 +1,37 @@
+package glog
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	defer resetOutput(setBuffer())
+	Warning("prometheus")
+
+	rec := httptest.NewRecorder()
+	MetricsHandler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body := rec.Body.String()
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("got content type %q", ct)
+	}
+	want := []string{
+		"# TYPE glog_lines_total counter\n",
+		fmt.Sprintf("glog_lines_total{severity=\"WARNING\"} %d\n", Stats.Warning.Lines()),
+		fmt.Sprintf("glog_bytes_total{severity=\"WARNING\"} %d\n", Stats.Warning.Bytes()),
+		"glog_lines_total{severity=\"FATAL\"} ",
+		"glog_backend_events_dropped_total ",
+		"# TYPE glog_write_duration_seconds histogram\n",
+		"glog_write_duration_seconds_bucket{le=\"1e-06\"} ",
+		"glog_write_duration_seconds_bucket{le=\"+Inf\"} ",
+		"glog_write_duration_seconds_count ",
+	}
+	for _, w := range want {
+		if !strings.Contains(body, w) {
+			t.Errorf("metrics do not contain %q:\n%s", w, body)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package glog
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// PanicError describes a panic caught by Recover and its relatives. It is
+// passed to backends as an ErrorArg.
+type PanicError struct {
+	// Value is the value passed to panic.
+	Value interface{}
+	// Stack is the stack trace of the panicking goroutine.
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.Value)
+}
+
+// Unwrap returns the value passed to panic if it is an error.
+func (e *PanicError) Unwrap() error {
+	err, _ := e.Value.(error)
+	return err
+}
+
+// Recover logs a panic in progress, if any, to the ERROR log along with the
+// stack trace of the panicking goroutine, and stops the panic.
+// It must be deferred directly:
+//
+//	defer glog.Recover()
+func Recover() {
+	if r := recover(); r != nil {
+		logging.logPanic(errorLog, r, nil)
+	}
+}
+
+// RecoverAndPanic is like Recover, but continues panicking once the panic
+// has been logged.
+func RecoverAndPanic() {
+	if r := recover(); r != nil {
+		logging.logPanic(errorLog, r, nil)
+		panic(r)
+	}
+}
+
+// RecoverFatal is like Recover, but logs the panic to the FATAL log, which
+// exits the program. The message leaves out the stack trace, because the
+// FATAL log ends with the stacks of all goroutines.
+func RecoverFatal() {
+	if r := recover(); r != nil {
+		logging.logPanic(fatalLog, r, nil)
+	}
+}
+
+// Go runs fn in a new goroutine, logging any panic in the manner of Recover.
+func Go(fn func()) {
+	go func() {
+		defer Recover()
+		fn()
+	}()
+}
+
+// logPanic logs the value r recovered from a panic, with the prefix and data
+// of logger if it is not nil. It must be called directly by the deferred
+// function that recovered it, so that the log header shows the line that
+// panicked, even if the panic came from within the runtime, as for a nil
+// map write. Below FATAL, the message includes the stack trace.
+func (l *loggingT) logPanic(s severity, r interface{}, logger *Logger) {
+	err := &PanicError{Value: r, Stack: stacks(false)}
+	args := []interface{}{err}
+	if s < fatalLog {
+		args = append(args, "\n", string(err.Stack))
+	}
+	if logger != nil {
+		args = logger.extend(args)
+	}
+	l.printWithDepth(s, panicDepth(), logger, args...)
+}
+
+// panicDepth returns the depth, relative to the caller of logPanic, of the
+// line that panicked: the first frame outside the runtime below the call
+// to runtime.gopanic. It returns 2, the depth for a call to panic, if
+// there is no such frame.
+func panicDepth() int {
+	var pcs [64]uintptr
+	n := runtime.Callers(3, pcs[:]) // start at the caller of logPanic
+	frames := runtime.CallersFrames(pcs[:n])
+	panicking := false
+	for depth := 0; ; depth++ {
+		frame, more := frames.Next()
+		if panicking && !strings.HasPrefix(frame.Function, "runtime.") {
+			return depth
+		}
+		if frame.Function == "runtime.gopanic" {
+			panicking = true
+		}
+		if !more {
+			return 2
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package glog
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecover(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	comm := RegisterBackend()
+	var line int
+	func() {
+		defer Recover()
+		_, _, line, _ = runtime.Caller(0)
+		panic("recover test")
+	}()
+
+	if !contains("panic: recover test", t) {
+		t.Errorf("panic value was not logged: %q", contents())
+	}
+	if !strings.HasPrefix(contents(), "E") || !contains(fmt.Sprintf("glog_recover_test.go:%d]", line+1), t) {
+		t.Errorf("header does not show the panicking line %d: %q", line+1, contents())
+	}
+	if !contains("goroutine ", t) {
+		t.Errorf("stack trace was not logged: %q", contents())
+	}
+
+	timeout := time.After(time.Second)
+	for {
+		select {
+		case e := <-comm:
+			if !strings.Contains(string(e.Message), "recover test") {
+				continue
+			}
+			if e.Severity != "ERROR" {
+				t.Errorf("got severity %s, want ERROR", e.Severity)
+			}
+			if len(e.StackTrace) == 0 {
+				t.Error("event has no stack trace")
+			}
+			for _, d := range e.Data {
+				if arg, ok := d.(ErrorArg); ok {
+					if pe, ok := arg.Error.(*PanicError); !ok || pe.Value != "recover test" {
+						t.Errorf("got error arg %#v, want a *PanicError", arg.Error)
+					}
+					return
+				}
+			}
+			t.Errorf("event has no ErrorArg: %v", e.Data)
+			return
+		case <-timeout:
+			t.Fatal("timed out waiting for panic event")
+		}
+	}
+}
+
+func TestRecoverRuntimeError(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	var line int
+	for name, fn := range map[string]func(){
+		"nil map": func() {
+			var m map[string]int
+			_, _, line, _ = runtime.Caller(0)
+			m["x"] = 1
+		},
+		"nil pointer": func() {
+			var p *int
+			_, _, line, _ = runtime.Caller(0)
+			*p = 1
+		},
+	} {
+		fakeStdout.Reset()
+		func() {
+			defer Recover()
+			fn()
+		}()
+		if !contains("] panic: ", t) {
+			t.Errorf("%s: panic was not logged: %q", name, contents())
+		}
+		if !contains(fmt.Sprintf(" glog_recover_test.go:%d]", line+1), t) {
+			t.Errorf("%s: header does not show the panicking line %d: %q", name, line+1, contents())
+		}
+	}
+}
+
+func TestRecoverAndPanic(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	defer func() {
+		if r := recover(); r != "repanic test" {
+			t.Errorf("got panic %v, want repanic test", r)
+		}
+		if !contains("panic: repanic test", t) {
+			t.Errorf("panic value was not logged: %q", contents())
+		}
+	}()
+	defer RecoverAndPanic()
+	panic("repanic test")
+}
+
+func TestRecoverFatal(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	var code int
+	defer SetExitFunc(SetExitFunc(func(c int) { code = c }))
+	func() {
+		defer RecoverFatal()
+		panic("fatal test")
+	}()
+
+	if code != ExitCodeFatal {
+		t.Errorf("got exit code %d, want %d", code, ExitCodeFatal)
+	}
+	if !contains("F", t) || !contains("panic: fatal test", t) {
+		t.Errorf("panic was not logged to FATAL: %q", contents())
+	}
+	// The stack appears once, in the dump of all goroutines.
+	if n := strings.Count(contents(), "glog.TestRecoverFatal.func"); n != 1 {
+		t.Errorf("stack of the panicking goroutine logged %d times: %s", n, contents())
+	}
+}
+
+func TestLoggerGo(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	WithPrefix("examplePrefix").Go(func() {
+		panic(fmt.Errorf("go test"))
+	})
+
+	timeout := time.After(time.Second)
+	for {
+		logging.mu.Lock()
+		logged := strings.Contains(contents(), "panic: go test")
+		logging.mu.Unlock()
+		if logged {
+			break
+		}
+		select {
+		case <-timeout:
+			t.Fatal("timed out waiting for panic to be logged")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if !contains("examplePrefix", t) {
+		t.Errorf("panic was not logged with prefix: %q", contents())
+	}
+}
@@ -0,0 +1,154 @@
+package glog
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sync"
+	"sync/atomic"
+)
+
+// A Redactor is a value that may hold sensitive information, such as a
+// credential or a personal detail. Whether it is passed to a logging call
+// as an argument, as an error or tagged by Data, glog uses the result of
+// its Redact method in place of the value itself.
+type Redactor interface {
+	Redact() string
+}
+
+// Redacted is the redacted form of a value. It is used in place of a
+// Redactor in log arguments and in Event.Data, and prints as itself
+// whatever the formatting verb.
+type Redacted string
+
+func (r Redacted) String() string {
+	return string(r)
+}
+
+// Format implements fmt.Formatter.
+func (r Redacted) Format(f fmt.State, verb rune) {
+	io.WriteString(f, string(r))
+}
+
+// Secret is a string that is logged as "[REDACTED]", for marking values
+// that must never appear in logs:
+//
+//	glog.Errorf("login failed for token %v", glog.Secret(token))
+//
+// It also prints as "[REDACTED]" with the fmt package whatever the verb,
+// so it stays hidden inside structs, slices, maps and wrapped errors.
+type Secret string
+
+// Redact implements Redactor.
+func (Secret) Redact() string {
+	return "[REDACTED]"
+}
+
+func (s Secret) String() string {
+	return s.Redact()
+}
+
+// GoString implements fmt.GoStringer.
+func (s Secret) GoString() string {
+	return s.Redact()
+}
+
+// Format implements fmt.Formatter.
+func (s Secret) Format(f fmt.State, verb rune) {
+	io.WriteString(f, s.Redact())
+}
+
+// redactedError stands in for an error that is a Redactor. It does not
+// unwrap, as the errors it wraps could reveal what was redacted.
+type redactedError struct {
+	msg string
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+// redact returns the redacted form of arg if it is a Redactor, and arg
+// itself otherwise.
+func redact(arg interface{}) interface{} {
+	switch r := arg.(type) {
+	case ErrorArg:
+		if rr, ok := r.Error.(Redactor); ok {
+			return ErrorArg{&redactedError{rr.Redact()}}
+		}
+	case Redactor:
+		return Redacted(r.Redact())
+	}
+	return arg
+}
+
+// A Scrubber replaces text matching a pattern in log messages. Scrubbers
+// catch sensitive values that were not marked as Redactors, at some cost:
+// each one is run over every message.
+type Scrubber struct {
+	Pattern *regexp.Regexp
+	// Replacement is passed to Regexp.ReplaceAll, so it may refer to
+	// submatches as in "$1".
+	Replacement string
+}
+
+var (
+	// CreditCardScrubber masks numbers of 13 to 19 digits, optionally
+	// grouped with spaces or dashes, as used for payment card numbers.
+	CreditCardScrubber = Scrubber{
+		regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		"[REDACTED CARD]",
+	}
+	// BearerTokenScrubber masks the tokens in bearer authorization
+	// credentials, as in "Authorization: Bearer <token>".
+	BearerTokenScrubber = Scrubber{
+		regexp.MustCompile(`(?i)\b(bearer)\s+[A-Za-z0-9\-._~+/]+=*`),
+		"$1 [REDACTED]",
+	}
+	// EmailScrubber masks email addresses.
+	EmailScrubber = Scrubber{
+		regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		"[REDACTED EMAIL]",
+	}
+)
+
+var (
+	scrubbersMu sync.RWMutex
+	scrubbers   []Scrubber
+	// scrubberCount is len(scrubbers), so that logging calls can check
+	// for scrubbers without taking the lock. Use atomic ops.
+	scrubberCount int32
+)
+
+// SetScrubbers sets the scrubbers applied, in order, to the message of
+// each log line before it is written to the output or passed to the
+// backends, replacing any set before. The header is not scrubbed.
+// With no arguments, it removes them all.
+func SetScrubbers(s ...Scrubber) {
+	scrubbersMu.Lock()
+	defer scrubbersMu.Unlock()
+	scrubbers = append([]Scrubber(nil), s...)
+	atomic.StoreInt32(&scrubberCount, int32(len(s)))
+}
+
+// scrub applies the scrubbers to the message in buf, which starts at
+// offset start.
+func scrub(buf *buffer, start int) {
+	if atomic.LoadInt32(&scrubberCount) == 0 {
+		return
+	}
+	scrubbersMu.RLock()
+	defer scrubbersMu.RUnlock()
+	msg := buf.Bytes()[start:]
+	changed := false
+	for _, s := range scrubbers {
+		if s.Pattern.Match(msg) {
+			msg = s.Pattern.ReplaceAll(msg, []byte(s.Replacement))
+			changed = true
+		}
+	}
+	if changed {
+		buf.Truncate(start)
+		buf.Write(msg)
+	}
+}
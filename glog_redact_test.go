@@ -0,0 +1,97 @@
+package glog
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+type sensitiveError struct{ user string }
+
+func (e sensitiveError) Error() string  { return "no access for " + e.user }
+func (e sensitiveError) Redact() string { return "no access for [user]" }
+
+func TestRedactor(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	comm := RegisterBackend()
+	Errorf("token %v, pin %d", Secret("hunter2"), Secret("1234"))
+	Error("login failed: ", sensitiveError{"alice"}, Data(Secret("extra")))
+
+	if contains("hunter2", t) || contains("1234", t) || contains("alice", t) {
+		t.Errorf("sensitive values were logged: %q", contents())
+	}
+	if !contains("token [REDACTED], pin [REDACTED]\n", t) || !contains("login failed: no access for [user]\n", t) {
+		t.Errorf("redacted values were not logged: %q", contents())
+	}
+
+	e := <-comm
+	if strings.Contains(string(e.Message), "hunter2") {
+		t.Errorf("event message was not redacted: %q", e.Message)
+	}
+	e = <-comm
+	var errArg ErrorArg
+	var data interface{}
+	for _, d := range e.Data {
+		switch d := d.(type) {
+		case ErrorArg:
+			errArg = d
+		default:
+			data = d
+		}
+	}
+	if errArg.Error == nil || errArg.Error.Error() != "no access for [user]" {
+		t.Errorf("event error was not redacted: %v", errArg.Error)
+	}
+	if data != Redacted("[REDACTED]") {
+		t.Errorf("event data was not redacted: %#v", data)
+	}
+}
+
+func TestSecretNested(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	type credentials struct {
+		User  string
+		Token Secret
+	}
+	Infof("struct %v %+v %#v", credentials{"alice", "hunter2"}, credentials{"alice", "hunter2"}, credentials{"alice", "hunter2"})
+	Infof("slice %v, map %v", []Secret{"hunter2"}, map[string]Secret{"token": "hunter2"})
+	Error(fmt.Errorf("login failed: %w", fmt.Errorf("token %s", Secret("hunter2"))))
+
+	if contains("hunter2", t) {
+		t.Errorf("secrets were logged: %q", contents())
+	}
+	for _, want := range []string{
+		"struct {alice [REDACTED]} {User:alice Token:[REDACTED]} ",
+		"slice [[REDACTED]], map map[token:[REDACTED]]\n",
+		"login failed: token [REDACTED]\n",
+	} {
+		if !contains(want, t) {
+			t.Errorf("got %q, want it to contain %q", contents(), want)
+		}
+	}
+}
+
+func TestScrubbers(t *testing.T) {
+	defer resetOutput(setBuffer())
+	SetScrubbers(CreditCardScrubber, BearerTokenScrubber, EmailScrubber)
+	defer SetScrubbers()
+
+	comm := RegisterBackend()
+	Infof("charged 4111 1111 1111 1111 for bob@example.com with Authorization: Bearer abc.DEF-123=")
+
+	want := "charged [REDACTED CARD] for [REDACTED EMAIL] with Authorization: Bearer [REDACTED]\n"
+	if !contains(want, t) {
+		t.Errorf("got %q, want it to contain %q", contents(), want)
+	}
+	if e := <-comm; !strings.HasSuffix(string(e.Message), strings.TrimSuffix(want, "\n")) {
+		t.Errorf("event message was not scrubbed: %q", e.Message)
+	}
+
+	SetScrubbers()
+	Info("card 4111111111111111")
+	if !contains("card 4111111111111111", t) {
+		t.Errorf("message was scrubbed after the scrubbers were removed: %q", contents())
+	}
+}
@@ -0,0 +1,58 @@
+package glog
+
+import (
+	"context"
+	"time"
+)
+
+// retryPolicy retries failed sends with exponential backoff, for the
+// backends with MaxRetries, MinBackoff and MaxBackoff options.
+type retryPolicy struct {
+	maxRetries int
+	minBackoff time.Duration
+	maxBackoff time.Duration
+}
+
+// newRetryPolicy returns the policy for the given options, with the
+// defaults documented for WebhookOptions.
+func newRetryPolicy(maxRetries int, minBackoff, maxBackoff time.Duration) retryPolicy {
+	if maxRetries == 0 {
+		maxRetries = 5
+	}
+	if minBackoff <= 0 {
+		minBackoff = 100 * time.Millisecond
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = 30 * time.Second
+	}
+	return retryPolicy{maxRetries, minBackoff, maxBackoff}
+}
+
+// do calls send until it succeeds, reports a failure not worth retrying,
+// the retries run out or ctx is done, waiting longer after each failure.
+// It returns the result of the last call.
+func (p retryPolicy) do(ctx context.Context, send func() (retry bool, err error)) (retry bool, err error) {
+	backoff := p.minBackoff
+	for i := 0; ; i++ {
+		retry, err = send()
+		if err == nil || !retry || i >= p.maxRetries || sleep(ctx, backoff) != nil {
+			return retry, err
+		}
+		if backoff *= 2; backoff > p.maxBackoff {
+			backoff = p.maxBackoff
+		}
+	}
+}
+
+// sleep waits for d, and returns early with ctx's error if ctx is done
+// first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
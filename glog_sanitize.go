@@ -0,0 +1,164 @@
+package glog
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"unicode"
+	"unicode/utf8"
+)
+
+// sanitizing is 1 if arguments are sanitized. Use atomic ops.
+var sanitizing int32
+
+func init() {
+	flag.Var(sanitizeFlag{}, "log_sanitize", "escape control characters and invalid UTF-8 in logged arguments")
+}
+
+// SetSanitize turns sanitizing of logged arguments on or off, and returns
+// whether it was on. When on, control characters and invalid UTF-8 in the
+// arguments of logging calls are escaped, as in "\n" or "\x1b", so that
+// values such as those taken from HTTP requests cannot forge log lines or
+// send escape sequences to terminals. Format strings are left as they are,
+// as is the prefix of a Logger. Errors are sanitized too, so the detail
+// printed for them is on one line; use a Trusted Logger for content that
+// is meant to span lines.
+//
+// Sanitizing is off by default.
+func SetSanitize(on bool) bool {
+	var v int32
+	if on {
+		v = 1
+	}
+	return atomic.SwapInt32(&sanitizing, v) == 1
+}
+
+// sanitizeFlag is the flag.Value for -log_sanitize.
+type sanitizeFlag struct{}
+
+func (sanitizeFlag) String() string {
+	return strconv.FormatBool(atomic.LoadInt32(&sanitizing) == 1)
+}
+
+func (sanitizeFlag) Set(value string) error {
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	SetSanitize(on)
+	return nil
+}
+
+func (sanitizeFlag) IsBoolFlag() bool { return true }
+
+// sanitizeArgs returns args with control characters and invalid UTF-8
+// escaped, if sanitizing is on and lg is not trusted. Strings are escaped
+// directly, so that Print spaces them as before; other values that may
+// hold text are wrapped to be escaped once formatted. With formatted set,
+// strings are wrapped too, so that verbs such as %q see the original.
+// It returns args itself, rather than a copy, if it changes nothing.
+func sanitizeArgs(lg *Logger, args []interface{}, formatted bool) []interface{} {
+	if atomic.LoadInt32(&sanitizing) == 0 || (lg != nil && lg.trusted) {
+		return args
+	}
+	var r []interface{}
+	for i, arg := range args {
+		var sanitized interface{}
+		switch arg := arg.(type) {
+		case nil, bool, int, int8, int16, int32, int64,
+			uint, uint8, uint16, uint32, uint64, uintptr,
+			float32, float64, complex64, complex128:
+			// Nothing to escape, and Printf needs ints for '*' widths.
+			continue
+		case string:
+			if !needsSanitizing(arg) {
+				continue
+			}
+			if formatted {
+				sanitized = sanitizer{arg}
+			} else {
+				sanitized = sanitizeString(arg)
+			}
+		default:
+			sanitized = sanitizer{arg}
+		}
+		if r == nil {
+			r = make([]interface{}, len(args))
+			copy(r, args)
+		}
+		r[i] = sanitized
+	}
+	if r == nil {
+		return args
+	}
+	return r
+}
+
+// sanitizer formats a value as it would be formatted itself, then escapes
+// the result.
+type sanitizer struct {
+	arg interface{}
+}
+
+// Format implements fmt.Formatter.
+func (s sanitizer) Format(f fmt.State, verb rune) {
+	io.WriteString(f, sanitizeString(fmt.Sprintf(fmt.FormatString(f, verb), s.arg)))
+}
+
+// needsSanitizing reports whether s holds control characters other than
+// tab, or invalid UTF-8.
+func needsSanitizing(s string) bool {
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if (c < ' ' && c != '\t') || c == 0x7f {
+				return true
+			}
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if (r == utf8.RuneError && size == 1) || unicode.IsControl(r) {
+			return true
+		}
+		i += size
+	}
+	return false
+}
+
+// sanitizeString escapes the control characters other than tab, and the
+// bytes of invalid UTF-8, in s.
+func sanitizeString(s string) string {
+	if !needsSanitizing(s) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			b.WriteString(`\x`)
+			b.WriteByte(hexDigits[s[i]>>4])
+			b.WriteByte(hexDigits[s[i]&0xf])
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r != '\t' && unicode.IsControl(r):
+			if r < utf8.RuneSelf {
+				b.WriteString(`\x`)
+			} else {
+				b.WriteString(`\u00`)
+			}
+			b.WriteByte(hexDigits[r>>4])
+			b.WriteByte(hexDigits[r&0xf])
+		default:
+			b.WriteString(s[i : i+size])
+		}
+		i += size
+	}
+	return b.String()
+}
@@ -0,0 +1,72 @@
+package glog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeString(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"plain\ttext", "plain\ttext"},
+		{"a\r\nI0101 forged", `a\r\nI0101 forged`},
+		{"\x1b[31mred\x00", `\x1b[31mred\x00`},
+		{"héllo \xff \u0085", `héllo \xff \u0085`},
+	}
+	for _, tt := range tests {
+		if got := sanitizeString(tt.in); got != tt.want {
+			t.Errorf("sanitizeString(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetSanitize(SetSanitize(true))
+
+	evil := "bob\nE0101 00:00:00.000000 forged.go:1] pwned"
+	Infof("user %s logged in; %q %*d", evil, "a\nb", 3, 7)
+	if got := contents(); strings.Count(got, "\n") != 1 ||
+		!strings.HasSuffix(got, `] user bob\nE0101 00:00:00.000000 forged.go:1] pwned logged in; "a\nb"   7`+"\n") {
+		t.Errorf("Infof not sanitized: %q", got)
+	}
+
+	fakeStdout.Reset()
+	Info("user ", evil, errors.New("bad\x1b[0m"), []string{"x\ny"}, 1, 2)
+	if got := contents(); !strings.HasSuffix(got, `] user bob\nE0101 00:00:00.000000 forged.go:1] pwnedbad\x1b[0m[x\ny] 1 2`+"\n") {
+		t.Errorf("Info not sanitized: %q", got)
+	}
+
+	// The format string is the program's own, and is left alone.
+	fakeStdout.Reset()
+	Infof("one\ntwo %s", "three")
+	if got := contents(); !strings.HasSuffix(got, "] one\ntwo three\n") {
+		t.Errorf("format string sanitized: %q", got)
+	}
+
+	fakeStdout.Reset()
+	WithPrefix("[p]").Trusted().WithData("d").Info("line one\nline two")
+	if got := contents(); !strings.HasSuffix(got, "] [p]line one\nline two\n") {
+		t.Errorf("Trusted Logger sanitized: %q", got)
+	}
+
+	fakeStdout.Reset()
+	SetSanitize(false)
+	Info(evil)
+	if got := contents(); !strings.HasSuffix(got, "] "+evil+"\n") {
+		t.Errorf("sanitized when off: %q", got)
+	}
+}
+
+func TestSanitizeFlag(t *testing.T) {
+	defer SetSanitize(SetSanitize(false))
+	var f sanitizeFlag
+	if err := f.Set("true"); err != nil || f.String() != "true" {
+		t.Errorf("Set(true) gave error %v, value %s", err, f.String())
+	}
+	if err := f.Set("maybe"); err == nil {
+		t.Error("Set(maybe) did not fail")
+	}
+}
@@ -0,0 +1,189 @@
+package glog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// SyslogOptions configures a SyslogBackend.
+type SyslogOptions struct {
+	// Network is "udp", "tcp", "unix" or "unixgram". Stream networks use
+	// octet-counted framing (RFC 6587); datagram networks send one message
+	// per datagram.
+	Network string
+	// Addr is the address of the syslog server, or the path of its socket.
+	Addr string
+	// Facility is the syslog facility code, from 0 to 23. It defaults to 1
+	// (user-level) if nil.
+	Facility *int
+	// Hostname defaults to the name reported by os.Hostname.
+	Hostname string
+	// AppName defaults to the base name of the program.
+	AppName string
+	// SDID is the SD-ID of the structured data element that holds
+	// Event.Fingerprint and Event.Data. It defaults to "glog@32473".
+	SDID string
+	// Timeout bounds dialing and each write. It defaults to 5 seconds.
+	Timeout time.Duration
+}
+
+// SyslogBackend sends events to a syslog server in the RFC 5424 format.
+// The connection is made on first use, and remade if a write fails.
+type SyslogBackend struct {
+	opts     SyslogOptions
+	facility int
+	procID   string
+	sender   *netSender
+}
+
+// NewSyslogBackend creates a SyslogBackend. To send it every event:
+//
+//	glog.AddBackend(glog.NewSyslogBackend(opts))
+//
+// It panics if opts.Facility is out of range.
+func NewSyslogBackend(opts SyslogOptions) *SyslogBackend {
+	facility := 1
+	if opts.Facility != nil {
+		facility = *opts.Facility
+		if facility < 0 || facility > 23 {
+			panic(fmt.Sprintf("glog: syslog facility %d out of range", facility))
+		}
+	}
+	if opts.Hostname == "" {
+		opts.Hostname, _ = os.Hostname()
+	}
+	if opts.AppName == "" {
+		opts.AppName = filepath.Base(os.Args[0])
+	}
+	if opts.SDID == "" {
+		opts.SDID = "glog@32473"
+	}
+	if opts.Timeout == 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	return &SyslogBackend{
+		opts:     opts,
+		facility: facility,
+		procID:   strconv.Itoa(os.Getpid()),
+		sender:   &netSender{network: opts.Network, addr: opts.Addr, timeout: opts.Timeout},
+	}
+}
+
+// Handle sends e to the syslog server, reconnecting once if need be.
+func (b *SyslogBackend) Handle(e Event) error {
+	msg := b.format(e)
+	if b.isStream() {
+		msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+	}
+	return b.sender.send(msg)
+}
+
+// Flush does nothing, as events are sent as they are handled.
+func (b *SyslogBackend) Flush(ctx context.Context) error {
+	return nil
+}
+
+// Close closes the connection to the syslog server, if any.
+func (b *SyslogBackend) Close() error {
+	return b.sender.close()
+}
+
+func (b *SyslogBackend) isStream() bool {
+	return b.opts.Network != "udp" && b.opts.Network != "unixgram"
+}
+
+// syslogSeverity maps glog severities to syslog severities.
+var syslogSeverity = map[string]int{
+	"INFO":    6, // informational
+	"WARNING": 4, // warning
+	"ERROR":   3, // error
+	"FATAL":   2, // critical
+}
+
+// format formats e as an RFC 5424 message:
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID PARAM="VALUE"...] MSG
+func (b *SyslogBackend) format(e Event) []byte {
+	sev, ok := syslogSeverity[e.Severity]
+	if !ok {
+		sev = 5 // notice
+	}
+	t := e.Time
+	if t.IsZero() {
+		t = timeNow()
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "<%d>1 %s %s %s %s - ",
+		b.facility*8+sev,
+		t.Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogHeaderField(b.opts.Hostname, 255),
+		syslogHeaderField(b.opts.AppName, 48),
+		b.procID)
+
+	fields := dataFields(e.Data)
+	if e.Fingerprint != "" {
+		fields = append([]dataField{{"fingerprint", e.Fingerprint}}, fields...)
+	}
+	if len(fields) == 0 {
+		buf.WriteByte('-')
+	} else {
+		buf.WriteByte('[')
+		buf.WriteString(b.opts.SDID)
+		for _, f := range fields {
+			buf.WriteByte(' ')
+			buf.WriteString(syslogParamName(f.key))
+			buf.WriteString(`="`)
+			for _, c := range []byte(f.value) {
+				if c == '"' || c == '\\' || c == ']' {
+					buf.WriteByte('\\')
+				}
+				buf.WriteByte(c)
+			}
+			buf.WriteByte('"')
+		}
+		buf.WriteByte(']')
+	}
+
+	buf.WriteByte(' ')
+	buf.WriteString(e.text())
+	return buf.Bytes()
+}
+
+// syslogHeaderField returns s limited to printable ASCII and max bytes,
+// or "-" if it is empty.
+func syslogHeaderField(s string, max int) string {
+	b := make([]byte, 0, len(s))
+	for i := 0; i < len(s) && len(b) < max; i++ {
+		if s[i] > ' ' && s[i] < 0x7f {
+			b = append(b, s[i])
+		}
+	}
+	if len(b) == 0 {
+		return "-"
+	}
+	return string(b)
+}
+
+// syslogParamName returns s as a valid PARAM-NAME, replacing disallowed
+// characters with '_'.
+func syslogParamName(s string) string {
+	b := []byte(s)
+	if len(b) > 32 {
+		b = b[:32]
+	}
+	for i, c := range b {
+		if c <= ' ' || c >= 0x7f || c == '=' || c == ']' || c == '"' {
+			b[i] = '_'
+		}
+	}
+	if len(b) == 0 {
+		return "_"
+	}
+	return string(b)
+}
@@ -0,0 +1,157 @@
+package glog
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var syslogTestEvent = Event{
+	Severity: "ERROR",
+	Message:  []byte("E1018 12:00:00.000000 file.go:1] syslog test\n"),
+	Body:     "syslog test",
+	Data:     []interface{}{ErrorArg{errors.New(`bad "thing"]`)}, "extra"},
+	Time:     time.Date(2006, 1, 2, 15, 4, 5, 678901000, time.UTC),
+}
+
+func TestSyslogFormat(t *testing.T) {
+	b := NewSyslogBackend(SyslogOptions{Hostname: "host", AppName: "app"})
+	b.procID = "42"
+
+	got := string(b.format(syslogTestEvent))
+	want := `<11>1 2006-01-02T15:04:05.678901Z host app 42 - ` +
+		`[glog@32473 error="bad \"thing\"\]" root_cause="bad \"thing\"\]" data1="extra"] ` +
+		`syslog test`
+	if got != want {
+		t.Errorf("got  %s\nwant %s", got, want)
+	}
+
+	e := syslogTestEvent
+	e.Fingerprint = "0123456789abcdef"
+	if got := string(b.format(e)); !strings.Contains(got, `[glog@32473 fingerprint="0123456789abcdef" error=`) {
+		t.Errorf("unexpected message with fingerprint: %s", got)
+	}
+
+	e = syslogTestEvent
+	e.Severity, e.Data = "INFO", nil
+	if got := string(b.format(e)); !strings.HasPrefix(got, "<14>1 ") || !strings.Contains(got, " - - syslog test") {
+		t.Errorf("unexpected INFO message without data: %s", got)
+	}
+
+	kern := 0
+	b = NewSyslogBackend(SyslogOptions{Facility: &kern})
+	if got := string(b.format(syslogTestEvent)); !strings.HasPrefix(got, "<3>1 ") {
+		t.Errorf("unexpected kern message: %s", got)
+	}
+}
+
+func TestSyslogFacilityOutOfRange(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("NewSyslogBackend did not panic for facility 24")
+		}
+	}()
+	facility := 24
+	NewSyslogBackend(SyslogOptions{Facility: &facility})
+}
+
+func TestSyslogEventsFromAddOns(t *testing.T) {
+	b := NewSyslogBackend(SyslogOptions{})
+	if got := string(b.format(GetErrorEvent(errors.New("boom")))); !strings.HasSuffix(got, " boom") {
+		t.Errorf("unexpected message for GetErrorEvent: %s", got)
+	}
+	// Events made with NewEvent have no Body, so Message is sent.
+	e := NewEvent(errorLog, []byte("made by hand\n"), nil, 0)
+	if got := string(b.format(e)); !strings.HasSuffix(got, " made by hand") {
+		t.Errorf("unexpected message for NewEvent: %s", got)
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	b := NewSyslogBackend(SyslogOptions{Network: "udp", Addr: conn.LocalAddr().String()})
+	defer b.Close()
+	if err := b.Handle(syslogTestEvent); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, 4096)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); !strings.HasPrefix(got, "<11>1 ") || !strings.HasSuffix(got, "syslog test") {
+		t.Errorf("unexpected datagram: %q", got)
+	}
+}
+
+// readOctetCounted reads one octet-counted syslog frame.
+func readOctetCounted(r *bufio.Reader) (string, error) {
+	length, err := r.ReadString(' ')
+	if err != nil {
+		return "", err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(length, " "))
+	if err != nil {
+		return "", err
+	}
+	msg := make([]byte, n)
+	_, err = io.ReadFull(r, msg)
+	return string(msg), err
+}
+
+func testSyslogStream(t *testing.T, network, addr string) {
+	l, err := net.Listen(network, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	b := NewSyslogBackend(SyslogOptions{Network: network, Addr: l.Addr().String()})
+	defer b.Close()
+
+	for i := 0; i < 2; i++ {
+		if err := b.Handle(syslogTestEvent); err != nil {
+			t.Fatal(err)
+		}
+
+		conn, err := l.Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		msg, err := readOctetCounted(bufio.NewReader(conn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(msg, "<11>1 ") || !strings.HasSuffix(msg, "syslog test") {
+			t.Errorf("unexpected message: %q", msg)
+		}
+		// Drop the connection, so the next event must reconnect.
+		conn.Close()
+		for i := 0; i < 3; i++ {
+			// The first write after the server closes may still succeed.
+			b.Handle(syslogTestEvent)
+		}
+	}
+}
+
+func TestSyslogTCP(t *testing.T) {
+	testSyslogStream(t, "tcp", "127.0.0.1:0")
+}
+
+func TestSyslogUnix(t *testing.T) {
+	testSyslogStream(t, "unix", filepath.Join(t.TempDir(), "syslog.sock"))
+}
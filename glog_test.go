@@ -19,9 +19,12 @@ package glog
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -150,6 +153,7 @@ func TestVmoduleGlob(t *testing.T) {
 
 func TestLogBacktraceAt(t *testing.T) {
 	defer resetOutput(setBuffer())
+	defer logging.traceLocation.Set("")
 	// The peculiar style of this code simplifies line counting and maintenance of the
 	// tracing block below.
 	var infoLine string
@@ -183,9 +187,70 @@ func TestLogBacktraceAt(t *testing.T) {
 	}
 }
 
+func TestLogBacktraceAtUnset(t *testing.T) {
+	defer logging.traceLocation.Set("")
+	if err := logging.traceLocation.Set("glog_test.go:1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := logging.traceLocation.Set(""); err != nil {
+		t.Errorf("unsetting log_backtrace_at: %v", err)
+	}
+	if logging.traceLocation.isSet() {
+		t.Errorf("log_backtrace_at is still set to %s", logging.traceLocation.String())
+	}
+}
+
 func BenchmarkHeader(b *testing.B) {
 	defer resetOutput(setBuffer())
 	for i := 0; i < b.N; i++ {
 		logging.putBuffer(logging.header(infoLog))
 	}
 }
+
+// withoutBackends hides any registered backends until the returned function
+// is called.
+func withoutBackends() func() {
+	n := atomic.SwapInt32(&backendCount, 0)
+	return func() { atomic.StoreInt32(&backendCount, n) }
+}
+
+// Test that typical logging calls do not allocate when there are no backends.
+func TestAllocsWithoutBackends(t *testing.T) {
+	defer withoutBackends()()
+	defer SetOutput(os.Stdout)
+	SetOutput(io.Discard)
+
+	tests := map[string]func(){
+		"Info":   func() { Info("test") },
+		"Infoln": func() { Infoln("test", "line") },
+		"Infof":  func() { Infof("test %s %d", "formatted", 1) },
+		"Error":  func() { Error("test") },
+		"Errorf": func() { Errorf("test %s", "formatted") },
+		"V":      func() { V(1).Info("test") },
+	}
+	for name, f := range tests {
+		if allocs := testing.AllocsPerRun(100, f); allocs != 0 {
+			t.Errorf("%s: got %v allocs, want 0", name, allocs)
+		}
+	}
+}
+
+func BenchmarkInfo(b *testing.B) {
+	defer withoutBackends()()
+	defer SetOutput(os.Stdout)
+	SetOutput(io.Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Info("test")
+	}
+}
+
+func BenchmarkInfof(b *testing.B) {
+	defer withoutBackends()()
+	defer SetOutput(os.Stdout)
+	SetOutput(io.Discard)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Infof("test %s %d", "formatted", 1)
+	}
+}
@@ -0,0 +1,164 @@
+package glog
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"unicode/utf8"
+)
+
+var (
+	// maxRecordSize and maxDataSize are the limits set by SetMaxRecordSize
+	// and SetMaxDataSize, or zero for none. Use atomic ops.
+	maxRecordSize int64
+	maxDataSize   int64
+)
+
+func init() {
+	flag.Var(sizeFlag{&maxRecordSize}, "log_max_record_size", "maximum size in bytes of the header and message of a log line, or 0 for no limit")
+	flag.Var(sizeFlag{&maxDataSize}, "log_max_data_size", "maximum size in bytes of the data of a log event, or 0 for no limit")
+}
+
+// SetMaxRecordSize limits the size of each log line, and returns the
+// previous limit. Lines of more than n bytes, not counting the final
+// newline, have their message cut and marked, as in "... [truncated 1234
+// bytes]", so that they come to n bytes at most, marker included. For
+// LogfmtEncoder and JSONEncoder, that counts the quoting of the message
+// and the data fields that follow it. Only the message is cut, so a line
+// whose other parts come to more than n bytes is kept whole but for the
+// message. The message is cut after scrubbing, and events passed to
+// backends carry the cut message. Zero, the default, means no limit.
+func SetMaxRecordSize(n int) int {
+	return int(atomic.SwapInt64(&maxRecordSize, int64(n)))
+}
+
+// SetMaxDataSize limits the size of the data of each log event, and
+// returns the previous limit. Items tagged by Data, and the errors logged,
+// are kept in order while their total size is no more than n bytes; the
+// rest are dropped and replaced by a TruncatedData. The size of an item is
+// that of its text, as formatted with fmt.Sprint. The FormatStringArg
+// added by Infof and the like is not counted. Zero, the default, means no
+// limit.
+func SetMaxDataSize(n int) int {
+	return int(atomic.SwapInt64(&maxDataSize, int64(n)))
+}
+
+// TruncatedData takes the place of the items of Event.Data dropped to
+// keep within the limit set by SetMaxDataSize.
+type TruncatedData struct {
+	// Items is the number of items dropped.
+	Items int
+	// Bytes is their total size.
+	Bytes int
+}
+
+func (t TruncatedData) String() string {
+	return fmt.Sprintf("%d items, %d bytes", t.Items, t.Bytes)
+}
+
+// sizeFlag is the flag.Value for a size limit.
+type sizeFlag struct {
+	p *int64
+}
+
+func (f sizeFlag) String() string {
+	if f.p == nil {
+		return "0"
+	}
+	return strconv.FormatInt(atomic.LoadInt64(f.p), 10)
+}
+
+func (f sizeFlag) Set(value string) error {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return fmt.Errorf("negative size %d", n)
+	}
+	atomic.StoreInt64(f.p, n)
+	return nil
+}
+
+// truncateMessage cuts the line in buf, whose message starts at offset
+// start, to max bytes, or not at all if max is zero. It returns the number
+// of bytes cut.
+func truncateMessage(buf *buffer, start, max int) int {
+	if max <= 0 || buf.Len() <= max {
+		return 0
+	}
+	// Leave room for the marker, whose length depends on the number of
+	// bytes cut, which in turn depends on where the marker must start.
+	line := buf.Bytes()
+	var end, markerLen int
+	for {
+		end = max - markerLen
+		if end < start {
+			end = start
+		}
+		// Cut at the start of a character.
+		for end > start && !utf8.RuneStart(line[end]) {
+			end--
+		}
+		n := len("... [truncated  bytes]") + len(strconv.Itoa(buf.Len()-end))
+		if n <= markerLen {
+			break
+		}
+		markerLen = n
+	}
+	cut := buf.Len() - end
+	buf.Truncate(end)
+	fmt.Fprintf(buf, "... [truncated %d bytes]", cut)
+	return cut
+}
+
+// capData cuts data to the limit set by SetMaxDataSize. It returns the
+// data to log and the number of bytes cut.
+func capData(data []interface{}) ([]interface{}, int) {
+	max := int(atomic.LoadInt64(&maxDataSize))
+	if max <= 0 {
+		return data, 0
+	}
+	var n int
+	for i, d := range data {
+		size := dataSize(d)
+		if n+size <= max {
+			n += size
+			continue
+		}
+		dropped := TruncatedData{Items: len(data) - i}
+		for _, d := range data[i:] {
+			dropped.Bytes += dataSize(d)
+		}
+		return append(data[:i:i], dropped), dropped.Bytes
+	}
+	return data, 0
+}
+
+// countTruncated counts a record from which cut bytes were cut, if any,
+// in Stats.Truncated.
+func countTruncated(cut int) {
+	if cut > 0 {
+		atomic.AddInt64(&Stats.Truncated.lines, 1)
+		atomic.AddInt64(&Stats.Truncated.bytes, int64(cut))
+	}
+}
+
+// dataSize returns the size of the text of a data item.
+func dataSize(d interface{}) int {
+	switch d := d.(type) {
+	case string:
+		return len(d)
+	case []byte:
+		return len(d)
+	case ErrorArg:
+		if d.Error == nil {
+			return 0
+		}
+		return len(d.Error.Error())
+	case FormatStringArg:
+		return len(d.Format)
+	}
+	return len(fmt.Sprint(d))
+}
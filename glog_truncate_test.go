@@ -0,0 +1,97 @@
+package glog
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestMaxRecordSize(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetMaxRecordSize(SetMaxRecordSize(0))
+
+	Info("x")
+	header := len(contents()) - len("x\n")
+
+	fakeStdout.Reset()
+	SetMaxRecordSize(header + 35)
+	lines, bytes := Stats.Truncated.Lines(), Stats.Truncated.Bytes()
+	Infof("%s", strings.Repeat("a", 9)+"é"+strings.Repeat("b", 100))
+	// The marker takes 25 of the 35 bytes, and the cut would split the é,
+	// so it is left out.
+	if got, want := contents()[header:], "aaaaaaaaa... [truncated 102 bytes]\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if n := len(contents()) - len("\n"); n > header+35 {
+		t.Errorf("line of %d bytes is over the limit of %d", n, header+35)
+	}
+	if Stats.Truncated.Lines() != lines+1 || Stats.Truncated.Bytes() != bytes+102 {
+		t.Errorf("Stats.Truncated went from %d, %d to %d, %d", lines, bytes, Stats.Truncated.Lines(), Stats.Truncated.Bytes())
+	}
+
+	fakeStdout.Reset()
+	Info("short")
+	if got := contents()[header:]; got != "short\n" {
+		t.Errorf("short line changed: %q", got)
+	}
+	if Stats.Truncated.Lines() != lines+1 {
+		t.Error("short line counted as truncated")
+	}
+}
+
+func TestMaxRecordSizeStructured(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetEncoder(SetEncoder(ClassicEncoder))
+	defer SetMaxRecordSize(SetMaxRecordSize(160))
+
+	for _, enc := range []Encoder{LogfmtEncoder, JSONEncoder} {
+		SetEncoder(enc)
+		fakeStdout.Reset()
+		// Each quote takes two bytes once escaped, and the data field
+		// follows the message.
+		Info(strings.Repeat(`"`, 200), Data(map[string]string{"user": "alice"}))
+		line := strings.TrimSuffix(contents(), "\n")
+		if len(line) > 160 {
+			t.Errorf("with %T, line of %d bytes is over the limit of 160: %s", enc, len(line), line)
+		}
+		if !strings.Contains(line, "truncated") || !strings.Contains(line, "alice") {
+			t.Errorf("with %T, unexpected line: %s", enc, line)
+		}
+		if enc == JSONEncoder {
+			var v map[string]interface{}
+			if err := json.Unmarshal([]byte(line), &v); err != nil {
+				t.Errorf("invalid JSON %s: %v", line, err)
+			}
+		}
+	}
+}
+
+func TestMaxDataSize(t *testing.T) {
+	defer resetOutput(setBuffer())
+	defer SetMaxDataSize(SetMaxDataSize(10))
+	c := make(chan Event, 10)
+	b := ChanBackend(c)
+	AddBackend(b)
+	defer RemoveBackend(testContext(t), b)
+
+	lines := Stats.Truncated.Lines()
+	Error(errors.New("failed"), Data("1234"), Data(strings.Repeat("z", 50)), Data(7))
+	FlushBackends(testContext(t))
+	e := <-c
+	want := []interface{}{ErrorArg{}, "1234", TruncatedData{Items: 2, Bytes: 51}}
+	if len(e.Data) != len(want) {
+		t.Fatalf("got data %v, want %v", e.Data, want)
+	}
+	for i := 1; i < len(want); i++ {
+		if e.Data[i] != want[i] {
+			t.Errorf("data %d is %v, want %v", i, e.Data[i], want[i])
+		}
+	}
+	if Stats.Truncated.Lines() != lines+1 {
+		t.Error("event not counted as truncated")
+	}
+	if fields := dataFields(e.Data[2:]); fields[0] != (dataField{"truncated", "2 items, 51 bytes"}) {
+		t.Errorf("unexpected fields %v", fields)
+	}
+}
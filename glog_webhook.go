@@ -0,0 +1,241 @@
+package glog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// WebhookOptions configures a WebhookBackend.
+type WebhookOptions struct {
+	// URL is the endpoint to which batches of events are POSTed.
+	URL string
+	// Client defaults to an http.Client with a 10 second timeout.
+	Client *http.Client
+	// Header holds extra headers to send with each request, such as
+	// for authorization.
+	Header http.Header
+	// BatchSize is the maximum number of events in a batch. It defaults
+	// to 100.
+	BatchSize int
+	// BatchInterval is the longest an event waits for its batch to fill.
+	// It defaults to 5 seconds.
+	BatchInterval time.Duration
+	// MaxRetries is the number of times a failed request is retried, with
+	// exponential backoff from MinBackoff to MaxBackoff. They default to
+	// 5 retries, 100 milliseconds and 30 seconds. A negative MaxRetries
+	// disables retries.
+	MaxRetries int
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// SpoolDir, if set, is a directory in which to keep batches that could
+	// not be sent because of a network error, a server error or 429 Too
+	// Many Requests, to be sent once the endpoint recovers. Batches the
+	// endpoint rejects for any other reason are dropped.
+	SpoolDir string
+	// MaxSpoolFiles is the number of batches to keep in SpoolDir, beyond
+	// which the oldest are discarded. It defaults to 100.
+	MaxSpoolFiles int
+}
+
+// WebhookBackend POSTs batches of events to an HTTP endpoint as JSON:
+//
+//	{"events": [{"severity": "ERROR", "time": "...", "message": "...",
+//	    "fingerprint": "...", "group_key": "...", "error": "...",
+//	    "root_cause": "...", "root_cause_type": "...",
+//	    "data": {"key": "value"}}]}
+//
+// The fingerprint is Event.Fingerprint, and the group_key is the format
+// string given to Errorf and its relatives; either can be used to group
+// similar events.
+type WebhookBackend struct {
+	opts    WebhookOptions
+	sender  *httpSender
+	batcher *batcher
+	spoolN  uint64 // used to order spool files with the same timestamp
+}
+
+// NewWebhookBackend creates a WebhookBackend. To send it every error:
+//
+//	glog.AddBackend(glog.NewWebhookBackend(opts), glog.MinSeverity("ERROR"))
+func NewWebhookBackend(opts WebhookOptions) *WebhookBackend {
+	if opts.Client == nil {
+		opts.Client = &http.Client{Timeout: 10 * time.Second}
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	if opts.BatchInterval <= 0 {
+		opts.BatchInterval = 5 * time.Second
+	}
+	if opts.MaxSpoolFiles <= 0 {
+		opts.MaxSpoolFiles = 100
+	}
+	b := &WebhookBackend{opts: opts}
+	b.sender = &httpSender{
+		url:         opts.URL,
+		client:      opts.Client,
+		header:      opts.Header,
+		retry:       newRetryPolicy(opts.MaxRetries, opts.MinBackoff, opts.MaxBackoff),
+		retryStatus: retryServerErrors,
+	}
+	b.batcher = newBatcher(opts.BatchSize, opts.BatchInterval, b.sendBatch)
+	return b
+}
+
+// Handle adds e to the current batch. Errors sending batches are reported
+// to the backend error handler as they happen, so it always returns nil.
+func (b *WebhookBackend) Handle(e Event) error {
+	b.batcher.add(e)
+	return nil
+}
+
+// Flush sends the current batch without waiting for it to fill, then any
+// spooled batches, and returns the error from sending the current batch.
+func (b *WebhookBackend) Flush(ctx context.Context) error {
+	return b.batcher.flush(ctx)
+}
+
+// Close sends the current batch and stops the backend.
+func (b *WebhookBackend) Close() error {
+	b.batcher.close()
+	return nil
+}
+
+// webhookEvent is the JSON form of an Event.
+type webhookEvent struct {
+	Severity      string            `json:"severity"`
+	Time          time.Time         `json:"time"`
+	Message       string            `json:"message"`
+	Fingerprint   string            `json:"fingerprint,omitempty"`
+	GroupKey      string            `json:"group_key,omitempty"`
+	Error         string            `json:"error,omitempty"`
+	RootCause     string            `json:"root_cause,omitempty"`
+	RootCauseType string            `json:"root_cause_type,omitempty"`
+	Data          map[string]string `json:"data,omitempty"`
+}
+
+func newWebhookEvent(e Event) webhookEvent {
+	we := webhookEvent{
+		Severity:    e.Severity,
+		Time:        e.Time,
+		Message:     e.text(),
+		Fingerprint: e.Fingerprint,
+	}
+	var rest []interface{}
+	for _, d := range e.Data {
+		switch d := d.(type) {
+		case ErrorArg:
+			if we.Error != "" || d.Error == nil {
+				rest = append(rest, d)
+				continue
+			}
+			we.Error = d.Error.Error()
+			if root := d.RootCause(); root != nil {
+				we.RootCause = root.Error()
+				we.RootCauseType = fmt.Sprintf("%T", root)
+			}
+		case FormatStringArg:
+			we.GroupKey = d.Format
+		default:
+			rest = append(rest, d)
+		}
+	}
+	if fields := dataFields(rest); len(fields) > 0 {
+		we.Data = make(map[string]string, len(fields))
+		for _, f := range fields {
+			we.Data[f.key] = f.value
+		}
+	}
+	return we
+}
+
+// sendBatch sends a batch of events, spooling it if that fails in a way
+// worth retrying. Once a batch has been sent, or if it is empty, it tries
+// sending any spooled batches.
+func (b *WebhookBackend) sendBatch(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		b.unspool()
+		return nil
+	}
+	payload := struct {
+		Events []webhookEvent `json:"events"`
+	}{make([]webhookEvent, len(events))}
+	for i, e := range events {
+		payload.Events[i] = newWebhookEvent(e)
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		reportBackendError(b, err)
+		return err
+	}
+
+	if retry, err := b.sender.post(ctx, body); err != nil {
+		reportBackendError(b, err)
+		if retry {
+			b.spool(body)
+		}
+		return err
+	}
+	b.unspool()
+	return nil
+}
+
+// spoolFiles returns the paths of the spooled batches, oldest first.
+func (b *WebhookBackend) spoolFiles() []string {
+	files, _ := filepath.Glob(filepath.Join(b.opts.SpoolDir, "*.json"))
+	sort.Strings(files)
+	return files
+}
+
+// spool saves body in the spool directory, making room if necessary.
+func (b *WebhookBackend) spool(body []byte) {
+	if b.opts.SpoolDir == "" {
+		return
+	}
+	if err := os.MkdirAll(b.opts.SpoolDir, 0755); err != nil {
+		reportBackendError(b, err)
+		return
+	}
+	files := b.spoolFiles()
+	for len(files) >= b.opts.MaxSpoolFiles {
+		os.Remove(files[0])
+		files = files[1:]
+	}
+
+	name := fmt.Sprintf("%020d-%010d", time.Now().UnixNano(), atomic.AddUint64(&b.spoolN, 1))
+	tmp := filepath.Join(b.opts.SpoolDir, name+".tmp")
+	if err := os.WriteFile(tmp, body, 0644); err != nil {
+		reportBackendError(b, err)
+		os.Remove(tmp)
+		return
+	}
+	os.Rename(tmp, filepath.Join(b.opts.SpoolDir, name+".json"))
+}
+
+// unspool sends the spooled batches, oldest first, until one fails in a
+// way worth retrying. Batches the endpoint rejects are dropped.
+func (b *WebhookBackend) unspool() {
+	if b.opts.SpoolDir == "" {
+		return
+	}
+	for _, file := range b.spoolFiles() {
+		body, err := os.ReadFile(file)
+		if err == nil {
+			var retry bool
+			if retry, err = b.sender.postOnce(body); retry {
+				return
+			}
+			if err != nil {
+				reportBackendError(b, fmt.Errorf("dropping spooled batch %s: %v", filepath.Base(file), err))
+			}
+		}
+		os.Remove(file)
+	}
+}
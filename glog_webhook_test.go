@@ -0,0 +1,276 @@
+package glog
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookServer records the batches POSTed to it, failing with the status
+// returned by fail while it is non-zero.
+type webhookServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	fail     func() int
+	requests int
+	batches  [][]webhookEvent
+}
+
+func newWebhookServer() *webhookServer {
+	s := &webhookServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests++
+		if s.fail != nil {
+			if code := s.fail(); code != 0 {
+				w.WriteHeader(code)
+				return
+			}
+		}
+		var payload struct {
+			Events []webhookEvent `json:"events"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		s.batches = append(s.batches, payload.Events)
+	}))
+	return s
+}
+
+func (s *webhookServer) got() (requests int, batches [][]webhookEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests, s.batches
+}
+
+func webhookTestEvent(i int) Event {
+	return Event{
+		Severity: "ERROR",
+		Message:  []byte(fmt.Sprintf("E1018 12:00:00.000000 file.go:1] event %d\n", i)),
+		Body:     fmt.Sprintf("event %d", i),
+		Data: []interface{}{
+			ErrorArg{errors.New("bad thing")},
+			FormatStringArg{"event %d"},
+			map[string]string{"user": "alice"},
+		},
+	}
+}
+
+func TestWebhookBatchSize(t *testing.T) {
+	s := newWebhookServer()
+	defer s.Close()
+
+	b := NewWebhookBackend(WebhookOptions{URL: s.URL, BatchSize: 2, BatchInterval: time.Hour})
+	for i := 0; i < 5; i++ {
+		b.Handle(webhookTestEvent(i))
+	}
+	b.Close()
+
+	_, batches := s.got()
+	if len(batches) != 3 || len(batches[0]) != 2 || len(batches[1]) != 2 || len(batches[2]) != 1 {
+		t.Fatalf("expected batches of 2, 2 and 1 events, got %v", batches)
+	}
+	e := batches[1][0]
+	if e.Severity != "ERROR" || e.Message != "event 2" ||
+		e.GroupKey != "event %d" || e.Error != "bad thing" || e.RootCause != "bad thing" ||
+		e.RootCauseType != "*errors.errorString" || e.Data["user"] != "alice" {
+		t.Errorf("unexpected event: %+v", e)
+	}
+}
+
+func TestWebhookBatchInterval(t *testing.T) {
+	s := newWebhookServer()
+	defer s.Close()
+
+	b := NewWebhookBackend(WebhookOptions{URL: s.URL, BatchInterval: 10 * time.Millisecond})
+	defer b.Close()
+	b.Handle(webhookTestEvent(0))
+
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if _, batches := s.got(); len(batches) == 1 {
+			return
+		}
+	}
+	t.Error("batch was not sent after BatchInterval")
+}
+
+func TestWebhookRetry(t *testing.T) {
+	s := newWebhookServer()
+	defer s.Close()
+	failures := 2
+	s.fail = func() int {
+		if failures > 0 {
+			failures--
+			return http.StatusServiceUnavailable
+		}
+		return 0
+	}
+
+	b := NewWebhookBackend(WebhookOptions{URL: s.URL, MinBackoff: time.Millisecond})
+	b.Handle(webhookTestEvent(0))
+	b.Close()
+
+	if requests, batches := s.got(); requests != 3 || len(batches) != 1 {
+		t.Errorf("got %d requests and %d batches, want 3 and 1", requests, len(batches))
+	}
+}
+
+func TestWebhookNoRetryOnClientError(t *testing.T) {
+	s := newWebhookServer()
+	defer s.Close()
+	s.fail = func() int { return http.StatusBadRequest }
+
+	dir := t.TempDir()
+	b := NewWebhookBackend(WebhookOptions{URL: s.URL, MinBackoff: time.Millisecond, SpoolDir: dir})
+	b.Handle(webhookTestEvent(0))
+	b.Close()
+
+	if requests, _ := s.got(); requests != 1 {
+		t.Errorf("got %d requests, want 1", requests)
+	}
+	if files := b.spoolFiles(); len(files) != 0 {
+		t.Errorf("got %d spooled batches, want 0", len(files))
+	}
+}
+
+func TestWebhookStopRetrying(t *testing.T) {
+	s := newWebhookServer()
+	defer s.Close()
+	s.fail = func() int { return http.StatusServiceUnavailable }
+
+	b := NewWebhookBackend(WebhookOptions{
+		URL:           s.URL,
+		BatchInterval: time.Hour,
+		MaxRetries:    100,
+		MinBackoff:    time.Hour,
+	})
+	b.Handle(webhookTestEvent(0))
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := b.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Flush returned %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The next batch is retrying when Close is called.
+	b.Handle(webhookTestEvent(1))
+	go b.Flush(context.Background())
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		if requests, _ := s.got(); requests == 2 {
+			break
+		}
+	}
+	b.Close()
+	if d := time.Since(start); d > 5*time.Second {
+		t.Errorf("Flush and Close took %v", d)
+	}
+	if requests, _ := s.got(); requests != 2 {
+		t.Errorf("got %d requests, want 2", requests)
+	}
+}
+
+func TestWebhookSpool(t *testing.T) {
+	s := newWebhookServer()
+	defer s.Close()
+	down := true
+	s.fail = func() int {
+		if down {
+			return http.StatusInternalServerError
+		}
+		return 0
+	}
+
+	dir := filepath.Join(t.TempDir(), "spool")
+	b := NewWebhookBackend(WebhookOptions{
+		URL:           s.URL,
+		BatchInterval: time.Hour,
+		MaxRetries:    -1,
+		SpoolDir:      dir,
+		MaxSpoolFiles: 2,
+	})
+	defer b.Close()
+	for i := 0; i < 3; i++ {
+		b.Handle(webhookTestEvent(i))
+		b.Flush(context.Background())
+	}
+	if files := b.spoolFiles(); len(files) != 2 {
+		t.Fatalf("got %d spooled batches, want 2", len(files))
+	}
+
+	s.mu.Lock()
+	down = false
+	s.mu.Unlock()
+	b.Handle(webhookTestEvent(3))
+	b.Flush(context.Background())
+
+	if files := b.spoolFiles(); len(files) != 0 {
+		t.Errorf("got %d spooled batches after recovery, want 0", len(files))
+	}
+	_, batches := s.got()
+	var got []string
+	for _, batch := range batches {
+		for _, e := range batch {
+			got = append(got, e.Message)
+		}
+	}
+	want := []string{"event 3", "event 1", "event 2"}
+	if len(got) != len(want) {
+		t.Fatalf("got events %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("event %d: got %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestWebhookSpoolRejected(t *testing.T) {
+	s := newWebhookServer()
+	defer s.Close()
+	codes := []int{http.StatusInternalServerError, 0, http.StatusBadRequest}
+	s.fail = func() int {
+		code := codes[0]
+		codes = codes[1:]
+		return code
+	}
+	var reported []error
+	defer SetBackendErrorHandler(SetBackendErrorHandler(func(_ Backend, err error) { reported = append(reported, err) }))
+
+	b := NewWebhookBackend(WebhookOptions{
+		URL:           s.URL,
+		BatchInterval: time.Hour,
+		MaxRetries:    -1,
+		SpoolDir:      t.TempDir(),
+	})
+	defer b.Close()
+	b.Handle(webhookTestEvent(0))
+	b.Flush(context.Background())
+	if files := b.spoolFiles(); len(files) != 1 {
+		t.Fatalf("got %d spooled batches, want 1", len(files))
+	}
+
+	// The new batch is accepted, and the spooled one rejected.
+	b.Handle(webhookTestEvent(1))
+	b.Flush(context.Background())
+	if files := b.spoolFiles(); len(files) != 0 {
+		t.Errorf("got %d spooled batches after rejection, want 0", len(files))
+	}
+	if requests, _ := s.got(); requests != 3 {
+		t.Errorf("got %d requests, want 3", requests)
+	}
+	if len(reported) != 2 || !strings.Contains(reported[1].Error(), "dropping spooled batch") {
+		t.Errorf("unexpected errors reported: %v", reported)
+	}
+}
@@ -0,0 +1,280 @@
+package glog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// A Backend receives logged events. Unlike a channel returned by
+// RegisterBackend, a Backend is driven by glog itself: AddBackend starts a
+// worker goroutine that passes it events in the order they were logged.
+type Backend interface {
+	// Handle processes an event. Any error is passed to the handler set
+	// with SetBackendErrorHandler.
+	Handle(Event) error
+	// Flush processes any events the backend has buffered, giving up when
+	// ctx is done.
+	Flush(ctx context.Context) error
+	// Close flushes the backend and releases its resources. It is called
+	// once, by RemoveBackend or CloseBackends.
+	Close() error
+}
+
+var (
+	workersMu sync.Mutex
+	workers   []*backendWorker
+
+	backendErrorMu      sync.Mutex
+	backendErrorHandler = defaultBackendErrorHandler
+)
+
+// backendWorker passes the events queued for a Backend to it.
+type backendWorker struct {
+	b      Backend
+	events chan Event
+	// requests holds flush requests, and a final close request.
+	requests chan backendRequest
+	done     chan struct{}
+	// goid is the ID of the worker's goroutine.
+	goid string
+}
+
+type backendRequest struct {
+	ctx    context.Context
+	close  bool
+	result chan error
+}
+
+// AddBackend registers b to be passed events when they are logged. If
+// filters are given, only events accepted by all of them are passed, as
+// for RegisterBackend. Events are queued for the backend without blocking
+// the logging call, and dropped if its queue is full.
+func AddBackend(b Backend, filters ...BackendFilter) {
+	w := &backendWorker{
+		b:        b,
+		events:   make(chan Event, 100),
+		requests: make(chan backendRequest),
+		done:     make(chan struct{}),
+	}
+	started := make(chan string)
+	go w.loop(started)
+	w.goid = <-started
+
+	workersMu.Lock()
+	workers = append(workers, w)
+	workersMu.Unlock()
+	addBackendChan(w.events, filters)
+}
+
+// RemoveBackend stops passing events to b, then processes the events
+// already queued for it, and closes it. If ctx is done first, it returns
+// ctx.Err(), and b is closed once its queue has been processed. It does
+// nothing if b was not added with AddBackend. Backends are compared with ==, so b must be of
+// a comparable type, such as a pointer.
+func RemoveBackend(ctx context.Context, b Backend) error {
+	workersMu.Lock()
+	var w *backendWorker
+	for i, wi := range workers {
+		if wi.b == b {
+			w = wi
+			workers = append(workers[:i:i], workers[i+1:]...)
+			break
+		}
+	}
+	workersMu.Unlock()
+	if w == nil {
+		return nil
+	}
+	removeBackendChan(w.events)
+	return w.request(ctx, true)
+}
+
+// FlushBackends waits for the events already logged to be processed by
+// the backends added with AddBackend, and then flushes them. It returns
+// the first error, or ctx.Err() if ctx is done first.
+func FlushBackends(ctx context.Context) error {
+	workersMu.Lock()
+	ws := workers
+	workersMu.Unlock()
+	return requestAll(ctx, ws, false)
+}
+
+// flushBackendsOnExit is FlushBackends for runExit. It leaves out the
+// backend, if any, whose worker is exiting, as the worker cannot flush
+// its backend while waiting for itself to do so.
+func flushBackendsOnExit(ctx context.Context) error {
+	id := currentGoroutineID()
+	workersMu.Lock()
+	var ws []*backendWorker
+	for _, w := range workers {
+		if w.goid != id {
+			ws = append(ws, w)
+		}
+	}
+	workersMu.Unlock()
+	return requestAll(ctx, ws, false)
+}
+
+// currentGoroutineID returns the ID of the calling goroutine.
+func currentGoroutineID() string {
+	var buf [64]byte
+	return string(buf[:goroutineID(buf[:])])
+}
+
+// CloseBackends removes and closes all backends added with AddBackend,
+// as RemoveBackend does.
+func CloseBackends(ctx context.Context) error {
+	workersMu.Lock()
+	ws := workers
+	workers = nil
+	workersMu.Unlock()
+	for _, w := range ws {
+		removeBackendChan(w.events)
+	}
+	return requestAll(ctx, ws, true)
+}
+
+// requestAll makes a request of each worker in parallel, so that one slow
+// backend does not use up the time allowed for the others.
+func requestAll(ctx context.Context, ws []*backendWorker, close bool) error {
+	errs := make([]error, len(ws))
+	var wg sync.WaitGroup
+	for i, w := range ws {
+		wg.Add(1)
+		go func(i int, w *backendWorker) {
+			defer wg.Done()
+			errs[i] = w.request(ctx, close)
+		}(i, w)
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetBackendErrorHandler sets the function called when a Backend returns
+// an error from Handle, and returns the previous one. The default prints
+// the error to os.Stderr, since logging it could lead to more errors.
+// Passing nil restores the default.
+//
+// The handler is called from the backend's worker goroutine, and must not
+// log at a severity the backend handles.
+func SetBackendErrorHandler(f func(b Backend, err error)) func(b Backend, err error) {
+	if f == nil {
+		f = defaultBackendErrorHandler
+	}
+	backendErrorMu.Lock()
+	defer backendErrorMu.Unlock()
+	previous := backendErrorHandler
+	backendErrorHandler = f
+	return previous
+}
+
+func defaultBackendErrorHandler(b Backend, err error) {
+	fmt.Fprintf(os.Stderr, "glog: backend %T: %v\n", b, err)
+}
+
+// reportBackendError counts err and passes it to the backend error handler.
+func reportBackendError(b Backend, err error) {
+	atomic.AddInt64(&backendStats.failed, 1)
+	backendErrorMu.Lock()
+	f := backendErrorHandler
+	backendErrorMu.Unlock()
+	f(b, err)
+}
+
+// request asks the worker to flush, or close, the backend once it has
+// processed the events queued so far, and waits for the result. A close
+// request is made even if ctx is done first, as the worker has been
+// removed, and nothing else would close the backend.
+func (w *backendWorker) request(ctx context.Context, close bool) error {
+	req := backendRequest{ctx, close, make(chan error, 1)}
+	if close {
+		go func() {
+			select {
+			case w.requests <- req:
+			case <-w.done:
+			}
+		}()
+	} else {
+		select {
+		case w.requests <- req:
+		case <-w.done:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	select {
+	case err := <-req.result:
+		return err
+	case <-w.done:
+		// The result, if any, was sent before the worker stopped.
+		select {
+		case err := <-req.result:
+			return err
+		default:
+			return nil
+		}
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *backendWorker) loop(started chan<- string) {
+	defer close(w.done)
+	started <- currentGoroutineID()
+	for {
+		select {
+		case e := <-w.events:
+			w.handle(e)
+		case req := <-w.requests:
+			// Events queued before the request are handled first.
+			for n := len(w.events); n > 0; n-- {
+				w.handle(<-w.events)
+			}
+			if req.close {
+				req.result <- w.b.Close()
+				return
+			}
+			req.result <- w.b.Flush(req.ctx)
+		}
+	}
+}
+
+func (w *backendWorker) handle(e Event) {
+	if err := w.b.Handle(e); err != nil {
+		reportBackendError(w.b, err)
+	}
+}
+
+// ChanBackend returns a Backend that passes events on c, for code written
+// to consume the channel returned by RegisterBackend. Handle blocks until
+// the event is received or there is room for it in c, and Close closes c.
+func ChanBackend(c chan<- Event) Backend {
+	return chanBackend{c}
+}
+
+type chanBackend struct {
+	c chan<- Event
+}
+
+func (b chanBackend) Handle(e Event) error {
+	b.c <- e
+	return nil
+}
+
+func (b chanBackend) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (b chanBackend) Close() error {
+	close(b.c)
+	return nil
+}
@@ -0,0 +1,234 @@
+package glog
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingBackend records the events it handles and the calls made to it.
+type recordingBackend struct {
+	mu     sync.Mutex
+	events []string
+	calls  []string
+	err    error
+}
+
+func (b *recordingBackend) Handle(e Event) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	msg := strings.SplitN(string(e.Message), "] ", 2)[1]
+	b.events = append(b.events, strings.TrimSuffix(msg, "\n"))
+	return b.err
+}
+
+func (b *recordingBackend) Flush(ctx context.Context) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = append(b.calls, "flush")
+	return nil
+}
+
+func (b *recordingBackend) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = append(b.calls, "close")
+	return nil
+}
+
+func (b *recordingBackend) got() (events, calls string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return strings.Join(b.events, ","), strings.Join(b.calls, ",")
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+func TestAddBackend(t *testing.T) {
+	defer resetOutput(setBuffer())
+	ctx := testContext(t)
+
+	all, errs := &recordingBackend{}, &recordingBackend{}
+	AddBackend(all)
+	AddBackend(errs, MinSeverity("ERROR"))
+	defer CloseBackends(ctx)
+
+	Info("worker info")
+	Error("worker error")
+	if err := FlushBackends(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if events, calls := all.got(); events != "worker info,worker error" || calls != "flush" {
+		t.Errorf("got events %q and calls %q", events, calls)
+	}
+	if events, _ := errs.got(); events != "worker error" {
+		t.Errorf("got events %q from filtered backend", events)
+	}
+
+	if err := RemoveBackend(ctx, all); err != nil {
+		t.Fatal(err)
+	}
+	Error("worker after remove")
+	if err := FlushBackends(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if events, calls := all.got(); events != "worker info,worker error" || calls != "flush,close" {
+		t.Errorf("got events %q and calls %q after RemoveBackend", events, calls)
+	}
+	if events, _ := errs.got(); events != "worker error,worker after remove" {
+		t.Errorf("got events %q from remaining backend", events)
+	}
+}
+
+func TestBackendErrorHandler(t *testing.T) {
+	defer resetOutput(setBuffer())
+	ctx := testContext(t)
+
+	var mu sync.Mutex
+	var reported []error
+	defer SetBackendErrorHandler(SetBackendErrorHandler(func(b Backend, err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		reported = append(reported, err)
+	}))
+
+	failed := GetMetrics().Backends.Failed
+	b := &recordingBackend{err: errors.New("backend down")}
+	AddBackend(b)
+	defer CloseBackends(ctx)
+
+	Info("worker failing")
+	if err := FlushBackends(ctx); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reported) != 1 || reported[0] != b.err {
+		t.Errorf("got reported errors %v, want [%v]", reported, b.err)
+	}
+	if got := GetMetrics().Backends.Failed - failed; got != 1 {
+		t.Errorf("got %d failed events, want 1", got)
+	}
+}
+
+func TestChanBackend(t *testing.T) {
+	defer resetOutput(setBuffer())
+	ctx := testContext(t)
+
+	c := make(chan Event, 10)
+	AddBackend(ChanBackend(c))
+	Info("worker chan")
+	if err := CloseBackends(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for e := range c {
+		got = append(got, string(e.Message))
+	}
+	if len(got) != 1 || !strings.HasSuffix(got[0], "] worker chan") {
+		t.Errorf("got events %q", got)
+	}
+}
+
+func TestFatalFlushesBackends(t *testing.T) {
+	defer resetOutput(setBuffer())
+	ctx := testContext(t)
+
+	b := &recordingBackend{}
+	AddBackend(b)
+	defer CloseBackends(ctx)
+
+	var events, calls string
+	defer SetExitFunc(SetExitFunc(func(int) { events, calls = b.got() }))
+	Fatal("worker fatal")
+
+	if events != "worker fatal" || calls != "flush" {
+		t.Errorf("at exit, got events %q and calls %q", events, calls)
+	}
+}
+
+// gatedBackend blocks in Handle until its gate is closed, and closes
+// closed when it is closed.
+type gatedBackend struct {
+	gate   chan struct{}
+	closed chan struct{}
+}
+
+func (b *gatedBackend) Handle(e Event) error {
+	<-b.gate
+	return nil
+}
+
+func (b *gatedBackend) Flush(ctx context.Context) error {
+	return nil
+}
+
+func (b *gatedBackend) Close() error {
+	close(b.closed)
+	return nil
+}
+
+func TestRemoveBackendTimeout(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	b := &gatedBackend{gate: make(chan struct{}), closed: make(chan struct{})}
+	AddBackend(b)
+	Info("worker busy")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := RemoveBackend(ctx, b); err != context.DeadlineExceeded {
+		t.Errorf("RemoveBackend returned %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// The backend is closed once it has caught up.
+	close(b.gate)
+	select {
+	case <-b.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("backend was not closed after RemoveBackend timed out")
+	}
+}
+
+// fatalBackend logs to FATAL from Handle.
+type fatalBackend struct {
+	recordingBackend
+}
+
+func (b *fatalBackend) Handle(e Event) error {
+	if strings.Contains(string(e.Message), "trigger fatal") {
+		Fatal("fatal from backend")
+	}
+	return nil
+}
+
+func TestFatalFromBackend(t *testing.T) {
+	defer resetOutput(setBuffer())
+
+	exited := make(chan time.Duration, 1)
+	var start time.Time
+	defer SetExitFunc(SetExitFunc(func(int) { exited <- time.Since(start) }))
+	b := &fatalBackend{}
+	AddBackend(b, MinSeverity("INFO"))
+	defer RemoveBackend(testContext(t), b)
+
+	start = time.Now()
+	Info("trigger fatal")
+	select {
+	case d := <-exited:
+		// Flushing the backends on exit leaves out the one exiting,
+		// rather than waiting for it to flush itself.
+		if d > 5*time.Second {
+			t.Errorf("exit took %v", d)
+		}
+	case <-time.After(15 * time.Second):
+		t.Fatal("Fatal from a backend did not exit")
+	}
+}
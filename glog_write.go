@@ -0,0 +1,88 @@
+package glog
+
+import (
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// WriteErrorPolicy determines what happens to a log line that cannot be
+// written to the output writer. Whatever the policy, the failure is counted
+// in Stats.WriteErrors and reported by LastWriteError.
+type WriteErrorPolicy int32
+
+const (
+	// WriteErrorIgnore drops the line. This is the default.
+	WriteErrorIgnore WriteErrorPolicy = iota
+	// WriteErrorRetry tries writing the rest of the line a few more times
+	// before dropping it.
+	WriteErrorRetry
+	// WriteErrorStderr writes the rest of the line to os.Stderr instead.
+	WriteErrorStderr
+	// WriteErrorExit flushes the logs and exits the program with
+	// ExitCodeWriteError.
+	WriteErrorExit
+)
+
+// writeRetries is the number of extra attempts made by WriteErrorRetry.
+const writeRetries = 3
+
+var writeErrorPolicy int32 // WriteErrorPolicy, accessed atomically
+
+// SetWriteErrorPolicy sets the policy for log lines that cannot be written
+// to the output writer.
+func SetWriteErrorPolicy(p WriteErrorPolicy) {
+	atomic.StoreInt32(&writeErrorPolicy, int32(p))
+}
+
+func getWriteErrorPolicy() WriteErrorPolicy {
+	return WriteErrorPolicy(atomic.LoadInt32(&writeErrorPolicy))
+}
+
+// LastWriteError returns the most recent error from the output writer, or
+// nil if every write has succeeded.
+func LastWriteError() error {
+	logging.writeErrMu.Lock()
+	defer logging.writeErrMu.Unlock()
+	return logging.writeErr
+}
+
+// write writes data, which holds the given number of lines, to w, applying
+// the write error policy if that fails. It returns the number of bytes
+// written and the error from w, if any.
+// If the policy is WriteErrorExit, the caller must call l.exit with the
+// error once it has released l.mu.
+func (l *loggingT) write(w *writer, data []byte, lines int) (int, error) {
+	start := time.Now()
+	n, err := w.Write(data)
+	writeLatency.observe(time.Since(start))
+	if err == nil {
+		w.flushLine()
+		return n, nil
+	}
+	policy := getWriteErrorPolicy()
+	if policy == WriteErrorRetry {
+		for i := 0; i < writeRetries && err != nil; i++ {
+			time.Sleep(time.Duration(i+1) * time.Millisecond)
+			var m int
+			m, err = w.Write(data[n:])
+			n += m
+		}
+		if err == nil {
+			w.flushLine()
+			return n, nil
+		}
+	}
+
+	atomic.AddInt64(&Stats.WriteErrors.lines, int64(lines))
+	atomic.AddInt64(&Stats.WriteErrors.bytes, int64(len(data)-n))
+	l.writeErrMu.Lock()
+	l.writeErr = err
+	l.writeErrMu.Unlock()
+
+	if policy == WriteErrorStderr && w.Writer != os.Stderr {
+		m, _ := os.Stderr.Write(data[n:])
+		n += m
+	}
+	return n, err
+}
@@ -0,0 +1,103 @@
+package glog
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// failingWriter fails the first failures writes, then writes to buf.
+type failingWriter struct {
+	failures int
+	buf      bytes.Buffer
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.failures > 0 {
+		w.failures--
+		return 0, errWriteFailed
+	}
+	return w.buf.Write(p)
+}
+
+func TestWriteErrorIgnore(t *testing.T) {
+	defer SetOutput(os.Stdout)
+	w := &failingWriter{failures: 1}
+	SetOutput(w)
+
+	lines, bytes := Stats.WriteErrors.Lines(), Stats.WriteErrors.Bytes()
+	Info("lost")
+	Info("kept")
+
+	if got := Stats.WriteErrors.Lines() - lines; got != 1 {
+		t.Errorf("got %d write error lines, want 1", got)
+	}
+	if Stats.WriteErrors.Bytes() <= bytes {
+		t.Error("write error bytes were not counted")
+	}
+	if err := LastWriteError(); err != errWriteFailed {
+		t.Errorf("got last write error %v, want %v", err, errWriteFailed)
+	}
+	if out := w.buf.String(); strings.Contains(out, "lost") || !strings.Contains(out, "kept") {
+		t.Errorf("unexpected output: %q", out)
+	}
+}
+
+func TestWriteErrorRetry(t *testing.T) {
+	defer SetOutput(os.Stdout)
+	defer SetWriteErrorPolicy(WriteErrorIgnore)
+	SetWriteErrorPolicy(WriteErrorRetry)
+	w := &failingWriter{failures: writeRetries}
+	SetOutput(w)
+
+	lines := Stats.WriteErrors.Lines()
+	Info("retried")
+
+	if got := Stats.WriteErrors.Lines() - lines; got != 0 {
+		t.Errorf("got %d write error lines, want 0", got)
+	}
+	if !strings.Contains(w.buf.String(), "retried") {
+		t.Errorf("line was not retried: %q", w.buf.String())
+	}
+}
+
+func TestWriteErrorStderr(t *testing.T) {
+	defer SetOutput(os.Stdout)
+	defer SetWriteErrorPolicy(WriteErrorIgnore)
+	SetWriteErrorPolicy(WriteErrorStderr)
+	SetOutput(&failingWriter{failures: 1})
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(stderr *os.File) { os.Stderr = stderr }(os.Stderr)
+	os.Stderr = w
+	Info("to stderr")
+	w.Close()
+
+	out, _ := io.ReadAll(r)
+	if !strings.Contains(string(out), "to stderr") {
+		t.Errorf("line was not written to stderr: %q", out)
+	}
+}
+
+func TestWriteErrorExit(t *testing.T) {
+	defer SetOutput(os.Stdout)
+	defer SetWriteErrorPolicy(WriteErrorIgnore)
+	SetWriteErrorPolicy(WriteErrorExit)
+	SetOutput(&failingWriter{failures: 1})
+
+	var code int
+	defer SetExitFunc(SetExitFunc(func(c int) { code = c }))
+	Info("exit")
+
+	if code != ExitCodeWriteError {
+		t.Errorf("got exit code %d, want %d", code, ExitCodeWriteError)
+	}
+}
@@ -11,6 +11,8 @@ type Logger struct {
 	prefix string
 	// Extra arguments to be appended to each request
 	data []interface{}
+	// trusted is set if arguments are not to be sanitized.
+	trusted bool
 }
 
 // NewLogger creates a Logger instance with no additional data.
@@ -55,6 +57,7 @@ func (l *Logger) WithPrefix(prefix string) *Logger {
 		loggingT: l.loggingT,
 		data:     l.data,
 		prefix:   prefix,
+		trusted:  l.trusted,
 	}
 }
 
@@ -65,6 +68,7 @@ func (l *Logger) WithData(vars ...interface{}) *Logger {
 		loggingT: l.loggingT,
 		data:     vars,
 		prefix:   l.prefix,
+		trusted:  l.trusted,
 	}
 }
 
@@ -77,42 +81,55 @@ func (l *Logger) AppendData(vars ...interface{}) *Logger {
 		loggingT: l.loggingT,
 		data:     append(newData, vars...),
 		prefix:   l.prefix,
+		trusted:  l.trusted,
+	}
+}
+
+// Trusted creates a Logger from an existing logger whose arguments are
+// not sanitized, for content known to be safe, such as text that is meant
+// to span several lines. See SetSanitize.
+func (l *Logger) Trusted() *Logger {
+	return &Logger{
+		loggingT: l.loggingT,
+		data:     l.data,
+		prefix:   l.prefix,
+		trusted:  true,
 	}
 }
 
 // Info is equivalent to the global Info function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Info(args ...interface{}) {
-	l.print(infoLog, l.extendWithPfx(args)...)
+	l.print(infoLog, l.extend(args)...)
 }
 
 // Infoln is equivalent to the global Infoln function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Infoln(args ...interface{}) {
-	l.println(infoLog, l.extendWithPfx(args)...)
+	l.println(infoLog, l.extend(args)...)
 }
 
 // Infof is equivalent to the global Infof function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Infof(format string, args ...interface{}) {
-	l.printf(infoLog, l.pfx(format), l.extend(args)...)
+	l.printf(infoLog, format, l.extend(args)...)
 }
 
 // Warning is equivalent to the global Warning function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Warning(args ...interface{}) {
-	l.print(warningLog, l.extendWithPfx(args)...)
+	l.print(warningLog, l.extend(args)...)
 }
 
 // Warningln is equivalent to the global Warningln function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Warningln(args ...interface{}) {
-	l.println(warningLog, l.extendWithPfx(args)...)
+	l.println(warningLog, l.extend(args)...)
 }
 
 // Warningf is equivalent to the global Warningf function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Warningf(format string, args ...interface{}) {
-	l.printf(warningLog, l.pfx(format), l.extend(args)...)
+	l.printf(warningLog, format, l.extend(args)...)
 }
 
 // Error is equivalent to the global Error function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Error(args ...interface{}) {
-	l.print(errorLog, l.extendWithPfx(args)...)
+	l.print(errorLog, l.extend(args)...)
 }
 
 // GetErrorEvent is equivalent to the global GetErrorEvent function, with the addition of prefix and data content from this Logger.
@@ -127,18 +144,18 @@ func (l *Logger) ErrorIf(err error, args ...interface{}) {
 			args = append(args, ": ")
 		}
 		args = append(args, err)
-		l.print(errorLog, l.extendWithPfx(args)...)
+		l.print(errorLog, l.extend(args)...)
 	}
 }
 
 // Errorln is equivalent to the global Errorln function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Errorln(args ...interface{}) {
-	l.println(errorLog, l.extendWithPfx(args)...)
+	l.println(errorLog, l.extend(args)...)
 }
 
 // Errorf is equivalent to the global Errorf function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Errorf(format string, args ...interface{}) {
-	l.printf(errorLog, l.pfx(format), l.extend(args)...)
+	l.printf(errorLog, format, l.extend(args)...)
 }
 
 // ErrorfIf is equivalent to the global ErrorfIf function, with the addition of prefix and data content from this Logger.
@@ -146,13 +163,13 @@ func (l *Logger) ErrorfIf(err error, format string, args ...interface{}) {
 	if err != nil {
 		format += ": %v"
 		args = append(args, err)
-		l.printf(errorLog, l.pfx(format), l.extend(args)...)
+		l.printf(errorLog, format, l.extend(args)...)
 	}
 }
 
 // Fatal is equivalent to the global Fatal function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Fatal(args ...interface{}) {
-	l.print(fatalLog, l.extendWithPfx(args)...)
+	l.print(fatalLog, l.extend(args)...)
 }
 
 // FatalIf is equivalent to the global FatalIf function, with the addition of prefix and data content from this Logger.
@@ -161,9 +178,9 @@ func (l *Logger) FatalIf(err error, args ...interface{}) {
 		if args != nil {
 			errStr := ": " + err.Error()
 			args = append(args, errStr)
-			l.print(fatalLog, l.extendWithPfx(args)...)
+			l.print(fatalLog, l.extend(args)...)
 		} else {
-			l.print(fatalLog, l.extendWithPfx([]interface{}{
+			l.print(fatalLog, l.extend([]interface{}{
 				err,
 			})...)
 		}
@@ -172,12 +189,42 @@ func (l *Logger) FatalIf(err error, args ...interface{}) {
 
 // Fatalln is equivalent to the global Fatalln function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Fatalln(args ...interface{}) {
-	l.println(fatalLog, l.extendWithPfx(args)...)
+	l.println(fatalLog, l.extend(args)...)
 }
 
 // Fatalf is equivalent to the global Fatalf function, with the addition of prefix and data content from this Logger.
 func (l *Logger) Fatalf(format string, args ...interface{}) {
-	l.printf(fatalLog, l.pfx(format), l.extend(args)...)
+	l.printf(fatalLog, format, l.extend(args)...)
+}
+
+// Recover is equivalent to the global Recover function, with the addition of prefix and data content from this Logger.
+func (l *Logger) Recover() {
+	if r := recover(); r != nil {
+		l.logPanic(errorLog, r, l)
+	}
+}
+
+// RecoverAndPanic is equivalent to the global RecoverAndPanic function, with the addition of prefix and data content from this Logger.
+func (l *Logger) RecoverAndPanic() {
+	if r := recover(); r != nil {
+		l.logPanic(errorLog, r, l)
+		panic(r)
+	}
+}
+
+// RecoverFatal is equivalent to the global RecoverFatal function, with the addition of prefix and data content from this Logger.
+func (l *Logger) RecoverFatal() {
+	if r := recover(); r != nil {
+		l.logPanic(fatalLog, r, l)
+	}
+}
+
+// Go is equivalent to the global Go function, with the addition of prefix and data content from this Logger.
+func (l *Logger) Go(fn func()) {
+	go func() {
+		defer l.Recover()
+		fn()
+	}()
 }
 
 func (l *Logger) extendWithPfx(args []interface{}) []interface{} {
@@ -199,3 +246,26 @@ func (l *Logger) extend(args []interface{}) []interface{} {
 func (l *Logger) pfx(log string) string {
 	return fmt.Sprintf("%v %v", l.prefix, log)
 }
+
+// prefixString returns the Logger's prefix, or "" if l is nil.
+func (l *Logger) prefixString() string {
+	if l == nil {
+		return ""
+	}
+	return l.prefix
+}
+
+// print, println and printf pass the Logger itself along with the
+// arguments, so that the prefix can be encoded on its own.
+
+func (l *Logger) print(s severity, args ...interface{}) {
+	l.printWithDepth(s, 1, l, args...)
+}
+
+func (l *Logger) println(s severity, args ...interface{}) {
+	l.printlnWithDepth(s, 1, l, args...)
+}
+
+func (l *Logger) printf(s severity, format string, args ...interface{}) {
+	l.printfWithDepth(s, 1, l, format, args...)
+}
@@ -1,6 +1,12 @@
 package glog
 
-import "golang.org/x/xerrors"
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
 
 // ErrorArg captures information about an error passed as an argument.
 // It is passed to backends as a data arg.
@@ -8,19 +14,182 @@ type ErrorArg struct {
 	Error error
 }
 
-// RootCause returns the innermost error.
+// RootCause returns the innermost error, following Unwrap. Where an error
+// wraps several, as those made by errors.Join do, it follows the first.
 func (xe ErrorArg) RootCause() error {
 	err := xe.Error
-	for {
-		wrapper, ok := err.(xerrors.Wrapper)
-		if !ok {
+	for err != nil {
+		next := unwrapErrors(err)
+		if len(next) == 0 || next[0] == nil {
 			break
 		}
-		err = wrapper.Unwrap()
+		err = next[0]
 	}
 	return err
 }
 
+// Chain returns the tree of errors wrapped by the error, or nil if there
+// is no error.
+func (xe ErrorArg) Chain() *ErrorNode {
+	if xe.Error == nil {
+		return nil
+	}
+	return newErrorNode(xe.Error, 0)
+}
+
+// An ErrorNode describes one layer of an error chain.
+type ErrorNode struct {
+	Error error
+	// Message is the layer's own part of the error message, without that
+	// of the errors it wraps where they can be told apart.
+	Message string
+	// Type is the error's type, as printed by the %T verb.
+	Type string
+	// Function, File and Line give the location at which the error was
+	// created, if it captured one as those from xerrors do.
+	Function string
+	File     string
+	Line     int
+	// Wrapped holds the errors this one wraps: one for errors with an
+	// Unwrap() error method, and any number for those with an
+	// Unwrap() []error method.
+	Wrapped []*ErrorNode
+}
+
+// maxErrorDepth bounds the depth of an error tree, in case of cycles.
+const maxErrorDepth = 100
+
+func newErrorNode(err error, depth int) *ErrorNode {
+	n := &ErrorNode{Error: err, Type: fmt.Sprintf("%T", err)}
+	wrapped := unwrapErrors(err)
+	if f, ok := err.(xerrors.Formatter); ok {
+		p := &framePrinter{}
+		if next := f.FormatError(p); next != nil && len(wrapped) == 0 {
+			// Some errors only reveal what they wrap through FormatError.
+			wrapped = []error{next}
+		}
+		n.Message = strings.TrimSpace(p.message.String())
+		n.Function, n.File, n.Line = parseFrame(p.detail.String())
+	} else {
+		n.Message = ownMessage(err, wrapped)
+	}
+	if depth < maxErrorDepth {
+		for _, w := range wrapped {
+			if w != nil {
+				n.Wrapped = append(n.Wrapped, newErrorNode(w, depth+1))
+			}
+		}
+	}
+	return n
+}
+
+// Walk calls f for n and each error it wraps, depth first, with the depth
+// of each below n.
+func (n *ErrorNode) Walk(f func(n *ErrorNode, depth int)) {
+	n.walk(f, 0)
+}
+
+func (n *ErrorNode) walk(f func(n *ErrorNode, depth int), depth int) {
+	f(n, depth)
+	for _, w := range n.Wrapped {
+		w.walk(f, depth+1)
+	}
+}
+
+// unwrapErrors returns the errors wrapped by err, as errors.Unwrap does
+// for a single error and errors.Is does for several.
+func unwrapErrors(err error) []error {
+	switch err := err.(type) {
+	case interface{ Unwrap() []error }:
+		return err.Unwrap()
+	case interface{ Unwrap() error }:
+		if next := err.Unwrap(); next != nil {
+			return []error{next}
+		}
+	}
+	return nil
+}
+
+// ownMessage removes the messages of the wrapped errors from err's, where
+// they are included in the usual way: "message: wrapped" for fmt.Errorf's
+// %w, and the wrapped messages one per line for errors.Join.
+func ownMessage(err error, wrapped []error) string {
+	msg := err.Error()
+	switch len(wrapped) {
+	case 0:
+		return msg
+	case 1:
+		if wrapped[0] == nil {
+			return msg
+		}
+		inner := wrapped[0].Error()
+		if msg == inner {
+			return ""
+		}
+		if strings.HasSuffix(msg, ": "+inner) {
+			return strings.TrimSuffix(msg, ": "+inner)
+		}
+		return msg
+	default:
+		var msgs []string
+		for _, w := range wrapped {
+			if w != nil {
+				msgs = append(msgs, w.Error())
+			}
+		}
+		if msg == strings.Join(msgs, "\n") {
+			return ""
+		}
+		return msg
+	}
+}
+
+// framePrinter is an xerrors.Printer that separates an error's message
+// from the detail, which holds the frame where it was created.
+type framePrinter struct {
+	message, detail strings.Builder
+	inDetail        bool
+}
+
+func (p *framePrinter) Print(args ...interface{}) {
+	p.writer().WriteString(fmt.Sprint(args...))
+}
+
+func (p *framePrinter) Printf(format string, args ...interface{}) {
+	p.writer().WriteString(fmt.Sprintf(format, args...))
+}
+
+func (p *framePrinter) Detail() bool {
+	p.inDetail = true
+	return true
+}
+
+func (p *framePrinter) writer() *strings.Builder {
+	if p.inDetail {
+		return &p.detail
+	}
+	return &p.message
+}
+
+// parseFrame parses a frame as printed by xerrors.Frame.Format:
+//
+//	function
+//	    file:line
+func parseFrame(detail string) (function, file string, line int) {
+	lines := strings.Split(strings.TrimSpace(detail), "\n")
+	if len(lines) < 2 {
+		return "", "", 0
+	}
+	function = strings.TrimSpace(lines[0])
+	loc := strings.TrimSpace(lines[1])
+	i := strings.LastIndexByte(loc, ':')
+	if i < 0 {
+		return function, loc, 0
+	}
+	line, _ = strconv.Atoi(loc[i+1:])
+	return function, loc[:i], line
+}
+
 // FormatStringArg is used to capture the raw format string passed to glog,
 // which often contains an error message without identifying details that
 // can be used to help group the message.
@@ -0,0 +1,84 @@
+package glog
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"golang.org/x/xerrors"
+)
+
+func TestRootCause(t *testing.T) {
+	inner := errors.New("inner")
+	tests := []struct {
+		err  error
+		want error
+	}{
+		{inner, inner},
+		{fmt.Errorf("outer: %w", inner), inner},
+		{fmt.Errorf("outer: %w", fmt.Errorf("middle: %w", inner)), inner},
+		{xerrors.Errorf("outer: %w", inner), inner},
+		{errors.Join(inner, errors.New("other")), inner},
+		{fmt.Errorf("outer: %v", inner), nil},
+	}
+	for _, tt := range tests {
+		want := tt.want
+		if want == nil {
+			want = tt.err
+		}
+		if got := (ErrorArg{tt.err}).RootCause(); got != want {
+			t.Errorf("RootCause of %q is %v, want %v", tt.err, got, want)
+		}
+	}
+	if got := (ErrorArg{}).RootCause(); got != nil {
+		t.Errorf("RootCause of nil is %v", got)
+	}
+}
+
+// describeChain lists the message and type of each layer of an error tree,
+// indented by depth.
+func describeChain(n *ErrorNode) string {
+	var lines []string
+	n.Walk(func(n *ErrorNode, depth int) {
+		lines = append(lines, fmt.Sprintf("%s%q %s", strings.Repeat("  ", depth), n.Message, n.Type))
+	})
+	return strings.Join(lines, "\n")
+}
+
+func TestErrorChain(t *testing.T) {
+	err := fmt.Errorf("request failed: %w", errors.Join(
+		fmt.Errorf("db: %w", errors.New("timeout")),
+		otherError{},
+	))
+	got := describeChain(ErrorArg{err}.Chain())
+	want := `"request failed" *fmt.wrapError
+  "" *errors.joinError
+    "db" *fmt.wrapError
+      "timeout" *errors.errorString
+    "other error" glog.otherError`
+	if got != want {
+		t.Errorf("got chain\n%s\nwant\n%s", got, want)
+	}
+	if (ErrorArg{}).Chain() != nil {
+		t.Error("nil error has a chain")
+	}
+}
+
+func TestErrorChainFrames(t *testing.T) {
+	_, file, line, _ := runtime.Caller(0)
+	err := xerrors.Errorf("outer: %w", xerrors.New("inner"))
+
+	n := ErrorArg{err}.Chain()
+	if n.Message != "outer" || len(n.Wrapped) != 1 || n.Wrapped[0].Message != "inner" {
+		t.Fatalf("unexpected chain:\n%s", describeChain(n))
+	}
+	for _, n := range []*ErrorNode{n, n.Wrapped[0]} {
+		if !strings.HasSuffix(n.Function, "TestErrorChainFrames") ||
+			filepath.Base(n.File) != filepath.Base(file) || n.Line != line+1 {
+			t.Errorf("%q has frame %s %s:%d, want %s:%d", n.Message, n.Function, n.File, n.Line, file, line+1)
+		}
+	}
+}